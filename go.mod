@@ -5,6 +5,6 @@ go 1.22.4
 require github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
 
 require (
-	github.com/gorilla/websocket v1.5.3 // indirect
-	github.com/slack-go/slack v0.17.3 // indirect
+	github.com/gorilla/websocket v1.5.3
+	github.com/slack-go/slack v0.17.3
 )
@@ -0,0 +1,239 @@
+// Package main provides state and helpers shared by the messaging platforms.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpng/obsidian-pa/src/executor"
+	"github.com/gpng/obsidian-pa/src/journal"
+	"github.com/gpng/obsidian-pa/src/prefs"
+	"github.com/gpng/obsidian-pa/src/prompts"
+	"github.com/gpng/obsidian-pa/src/sessions"
+	"github.com/gpng/obsidian-pa/src/usage"
+)
+
+// App holds state shared by all messaging platforms
+type App struct {
+	Registry *executor.Registry      // Configured executors in fallback order
+	Prefs    *prefs.Store            // Per-chat executor, model and permission mode
+	Mode     executor.PermissionMode // Default permission mode for chats without one
+	Ledger   *usage.Ledger           // Usage ledger (nil = usage not recorded)
+	Sessions *sessions.Store         // Resumable CLI sessions per conversation
+	Journal  *journal.Journal        // Transcript of every exchange (nil = not journaled)
+	Envelope *PromptEnvelope         // Context added around each prompt (nil = bare prompts)
+
+	// FileThreshold is the response length, in characters, above which responses are
+	// sent as a Markdown file with a summary (0 = only when asked for "as file")
+	FileThreshold int
+
+	// Commands are prompt commands from notes in CommandsDir, a vault folder (nil = only /start)
+	Commands    *prompts.Library
+	CommandsDir string
+
+	// SharedSession links the allowed Telegram, Slack and Discord users' direct chats into one session
+	SharedSession bool
+
+	// Expiry rolls idle sessions over into summary notes (zero = sessions never expire)
+	Expiry SessionExpiry
+
+	jobs    *jobQueue // Queued and running AI runs, across platforms
+	batches *batcher  // Messages waiting out the debounce window, per session key
+	homesMu sync.Mutex
+	homes   map[string]homeChat // Direct chat per platform, for /handoff
+}
+
+// checkBudget returns a refusal message if a budget cap has been reached, or "" to proceed
+func (a *App) checkBudget() string {
+	if a.Ledger == nil {
+		return ""
+	}
+
+	err := a.Ledger.CheckBudget(time.Now())
+	var budgetErr *usage.BudgetError
+	switch {
+	case errors.As(err, &budgetErr):
+		until := "tomorrow"
+		if budgetErr.Period == "monthly" {
+			until = "next month"
+		}
+		return fmt.Sprintf("💸 The %s. New runs are paused until %s.", budgetErr, until)
+	case err != nil:
+		// Don't block the user on a ledger read error
+		log.Printf("Failed to check budget: %v", err)
+	}
+	return ""
+}
+
+// recordUsage appends a finished run to the usage ledger
+func (a *App) recordUsage(platform string, res *executor.Result) {
+	if a.Ledger == nil {
+		return
+	}
+
+	err := a.Ledger.Add(usage.Record{
+		Time:             time.Now(),
+		Platform:         platform,
+		Executor:         res.Executor,
+		Model:            res.Model,
+		InputTokens:      res.Usage.InputTokens,
+		OutputTokens:     res.Usage.OutputTokens,
+		CacheReadTokens:  res.Usage.CacheReadTokens,
+		CacheWriteTokens: res.Usage.CacheWriteTokens,
+		CostUSD:          res.CostUSD,
+		DurationMS:       res.Duration.Milliseconds(),
+		Error:            string(res.ErrorKind),
+	})
+	if err != nil {
+		log.Printf("Failed to record usage: %v", err)
+	}
+}
+
+// recordJournal appends a finished exchange to the transcript journal, under the session
+// the run started in
+func (a *App) recordJournal(platform string, run sessions.Run, asked time.Time, prompt string, res *executor.Result) {
+	if a.Journal == nil {
+		return
+	}
+
+	response := res.Response
+	if res.Failed() {
+		response = failureMessage(res)
+	}
+	err := a.Journal.Add(journal.Entry{
+		Asked:        asked,
+		Answered:     time.Now(),
+		Platform:     platformName(platform),
+		Conversation: run.Key,
+		Session:      run.Name,
+		Executor:     res.Executor,
+		Model:        res.Model,
+		SessionID:    res.SessionID,
+		Prompt:       prompt,
+		Response:     response,
+		Failed:       res.Failed(),
+	})
+	if err != nil {
+		log.Printf("Failed to write journal: %v", err)
+	}
+}
+
+// sessionStatus describes a conversation's session for /status
+func (a *App) sessionStatus(key string) string {
+	session := a.Sessions.Get(key)
+	if !session.Active() {
+		return fmt.Sprintf("ℹ️ No active session (%s). Next message will start a new one.", session.Name)
+	}
+
+	names := make([]string, 0, len(session.IDs))
+	for name, id := range session.IDs {
+		if id != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	age := fmt.Sprintf("started %s ago, last used %s ago", formatAge(now.Sub(session.Created)), formatAge(now.Sub(session.LastUsed)))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🗂 Session: %s", session.Name)
+	if session.Title != "" {
+		fmt.Fprintf(&b, " - %s", session.Title)
+	}
+	switch len(names) {
+	case 0:
+		b.WriteString("\n🍴 Forked; the next message branches off")
+	case 1:
+		fmt.Fprintf(&b, "\n✅ Active session: %s (%s)", session.IDs[names[0]], names[0])
+	default:
+		b.WriteString("\n✅ Active sessions:")
+		for _, name := range names {
+			fmt.Fprintf(&b, "\n• %s: %s", name, session.IDs[name])
+		}
+	}
+	fmt.Fprintf(&b, "\n🕒 %s", age)
+	return b.String()
+}
+
+// formatAge formats a duration coarsely (e.g. 5m, 3h, 2d)
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return "<1m"
+}
+
+// usageReport returns today, week and month totals by executor and model
+func (a *App) usageReport() string {
+	if a.Ledger == nil {
+		return "ℹ️ Usage tracking is disabled."
+	}
+
+	now := time.Now()
+	periods := []struct {
+		name string
+		from time.Time
+	}{
+		{"Today", usage.StartOfDay(now)},
+		{"This week", usage.StartOfWeek(now)},
+		{"This month", usage.StartOfMonth(now)},
+	}
+
+	var b strings.Builder
+	b.WriteString("📊 **Usage**\n")
+	for _, period := range periods {
+		totals, err := a.Ledger.Totals(period.from, now)
+		if err != nil {
+			log.Printf("Failed to read usage ledger: %v", err)
+			return fmt.Sprintf("❌ Failed to read usage ledger: %v", err)
+		}
+
+		var runs int
+		var cost float64
+		for _, t := range totals {
+			runs += t.Runs
+			cost += t.CostUSD
+		}
+
+		fmt.Fprintf(&b, "\n**%s** - %d runs, $%.2f\n", period.name, runs, cost)
+		for _, t := range totals {
+			fmt.Fprintf(&b, "• %s / %s: %d runs, %s in / %s out, $%.2f\n",
+				t.Executor, t.Model, t.Runs, formatTokens(t.InputTokens), formatTokens(t.OutputTokens), t.CostUSD)
+		}
+	}
+
+	budget := a.Ledger.Budget()
+	if budget.DailyUSD > 0 || budget.MonthlyUSD > 0 {
+		b.WriteString("\n**Budget**\n")
+		if budget.DailyUSD > 0 {
+			fmt.Fprintf(&b, "• Daily cap: $%.2f\n", budget.DailyUSD)
+		}
+		if budget.MonthlyUSD > 0 {
+			fmt.Fprintf(&b, "• Monthly cap: $%.2f\n", budget.MonthlyUSD)
+		}
+	}
+
+	return b.String()
+}
+
+// formatTokens formats a token count compactly (e.g. 12.3k)
+func formatTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	}
+	return fmt.Sprintf("%d", n)
+}
@@ -0,0 +1,62 @@
+// Package main provides file attachments for responses too long to read as chat messages.
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gpng/obsidian-pa/src/render"
+)
+
+// DefaultFileThreshold is the response length, in characters, above which a response
+// is sent as a file unless FILE_RESPONSE_THRESHOLD says otherwise
+const DefaultFileThreshold = 10000
+
+// maxFileSummary is the length of the excerpt sent with a file. Telegram captions
+// allow 1024 characters, and emoji count twice.
+const maxFileSummary = 400
+
+// fileSuffixPattern matches a request for a file at the end of a message, e.g. "... as file"
+var fileSuffixPattern = regexp.MustCompile(`(?i)(?:^|\s+)as (?:an? )?(?:md |markdown )?file[.!]?\s*$`)
+
+// splitFileSuffix removes an "as file" request from the end of a message
+func splitFileSuffix(text string) (string, bool) {
+	loc := fileSuffixPattern.FindStringIndex(text)
+	if loc == nil {
+		return text, false
+	}
+	return strings.TrimSpace(text[:loc[0]]), true
+}
+
+// sendsAsFile reports whether a response goes out as a file: when asked for, or when
+// it is longer than the threshold
+func (a *App) sendsAsFile(response string, requested bool) bool {
+	return requested || (a.FileThreshold > 0 && utf8.RuneCountInString(response) > a.FileThreshold)
+}
+
+// responseFile names the file for a response after its first heading, or the time,
+// and writes the summary that goes with it: the title, length and opening lines
+func responseFile(response string, now time.Time) (name, summary string) {
+	body := strings.TrimSpace(response)
+	title := ""
+	if blocks := render.Parse(body); len(blocks) > 0 && blocks[0].Kind == render.Heading {
+		title = render.PlainText(render.ParseInline(blocks[0].Text))
+		_, body, _ = strings.Cut(body, "\n")
+	}
+
+	name = noteFileName(title) + ".md"
+	if strings.TrimSpace(title) == "" {
+		name = "Response " + now.Format("2006-01-02 1504") + ".md"
+		title = "Full response"
+	}
+
+	excerpt, more := render.Excerpt(strings.TrimSpace(body), maxFileSummary)
+	if more {
+		excerpt += "\n\n…"
+	}
+	summary = fmt.Sprintf("📎 **%s** · %d words\n\n%s", title, len(strings.Fields(response)), excerpt)
+	return name, strings.TrimSpace(summary)
+}
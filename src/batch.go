@@ -0,0 +1,151 @@
+// Package main provides batching of messages sent in quick succession into one prompt.
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batcher collects consecutive messages of a conversation until it has been quiet
+// for the debounce window, then flushes them together
+type batcher struct {
+	window time.Duration // Quiet period that ends a batch (0 = every message is its own batch)
+
+	mu      sync.Mutex
+	batches map[string]*batch
+}
+
+// chatMessage is a message received in a conversation
+type chatMessage struct {
+	Text string
+	Meta messageMeta
+}
+
+// batch is the messages collected so far for one session key
+type batch struct {
+	parts []chatMessage
+	timer *time.Timer
+	flush func(parts []chatMessage)
+}
+
+// newBatcher creates a batcher with the given debounce window
+func newBatcher(window time.Duration) *batcher {
+	return &batcher{window: window, batches: make(map[string]*batch)}
+}
+
+// add appends a message to the session's batch and restarts its debounce window.
+// flush is called with all collected messages once the window passes without a new one.
+func (b *batcher) add(key string, msg chatMessage, flush func(parts []chatMessage)) {
+	if b.window <= 0 {
+		flush([]chatMessage{msg})
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pending := b.batches[key]
+	if pending == nil {
+		pending = &batch{}
+		b.batches[key] = pending
+		pending.timer = time.AfterFunc(b.window, func() { b.flushBatch(key, pending) })
+	} else {
+		pending.timer.Reset(b.window)
+	}
+	pending.parts = append(pending.parts, msg)
+	pending.flush = flush
+}
+
+// flush sends the session's collected messages now, e.g. before a command that must run after them
+func (b *batcher) flush(key string) {
+	b.mu.Lock()
+	pending := b.batches[key]
+	b.mu.Unlock()
+
+	if pending != nil {
+		b.flushBatch(key, pending)
+	}
+}
+
+// flushBatch sends a batch if it is still the session's current one
+func (b *batcher) flushBatch(key string, pending *batch) {
+	b.mu.Lock()
+	if b.batches[key] != pending {
+		// Already flushed or dropped
+		b.mu.Unlock()
+		return
+	}
+	delete(b.batches, key)
+	pending.timer.Stop()
+	parts, flush := pending.parts, pending.flush
+	b.mu.Unlock()
+
+	flush(parts)
+}
+
+// drop discards the session's collected messages (on /cancel). Returns how many were dropped.
+func (b *batcher) drop(key string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pending := b.batches[key]
+	if pending == nil {
+		return 0
+	}
+	pending.timer.Stop()
+	delete(b.batches, key)
+	return len(pending.parts)
+}
+
+// collecting reports whether the session has messages waiting for the debounce window
+func (b *batcher) collecting(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.batches[key] != nil
+}
+
+// batchPrompt joins batched messages into one prompt with each message delimited.
+// A one-off "/write" or "@model" prefix on the first message applies to the whole batch.
+func (a *App) batchPrompt(parts []chatMessage) string {
+	if len(parts) == 1 {
+		return parts[0].Text
+	}
+
+	prefix, first := a.splitRunPrefix(parts[0].Text)
+	var b strings.Builder
+	b.WriteString(prefix)
+	fmt.Fprintf(&b, "I sent %d messages in a row. Treat them together as one request:\n", len(parts))
+	for i, part := range parts {
+		text := part.Text
+		if i == 0 {
+			text = first
+		}
+		fmt.Fprintf(&b, "\n--- Message %d ---\n%s\n", i+1, strings.TrimSpace(text))
+	}
+	b.WriteString("--- End of messages ---")
+	return b.String()
+}
+
+// batchMeta describes a batch by its first message, and the first message that replies to another.
+// The response is a file if any message asked for one.
+func batchMeta(parts []chatMessage) messageMeta {
+	meta := parts[0].Meta
+	for _, part := range parts {
+		if meta.ReplyTo == "" {
+			meta.ReplyTo = part.Meta.ReplyTo
+		}
+		meta.AsFile = meta.AsFile || part.Meta.AsFile
+	}
+	return meta
+}
+
+// batchLabel describes a batch for /queue
+func batchLabel(parts []chatMessage) string {
+	label := sessionTitle(parts[0].Text)
+	if len(parts) > 1 {
+		label += fmt.Sprintf(" (+%d more)", len(parts)-1)
+	}
+	return label
+}
@@ -0,0 +1,99 @@
+// Package main provides chat command parsing shared by the messaging platforms.
+package main
+
+import "strings"
+
+// commandTakesArg lists the built-in chat commands and whether each takes an argument.
+// Other commands, including /start, are prompt commands (see App.command).
+var commandTakesArg = map[string]bool{
+	"status":   false,
+	"reset":    false,
+	"cancel":   false,
+	"queue":    false,
+	"usage":    false,
+	"model":    true,
+	"executor": true,
+	"mode":     true,
+	"sessions": false,
+	"session":  true,
+	"handoff":  true,
+	"commands": false,
+}
+
+// commandSubcommands lists commands whose argument starts with a subcommand;
+// a bare command followed by one of them may take any number of words
+var commandSubcommands = map[string][]string{
+	"session": {"new", "switch", "fork"},
+}
+
+// parseCommand splits a message like "/model sonnet" into the command name and argument.
+// With bare set (Slack intercepts slash commands), the slash is optional, but a bare
+// word only counts as a command when followed by at most one more word or a subcommand.
+// Returns false if the message is not a known command.
+func parseCommand(text string, bare bool) (name, arg string, ok bool) {
+	name, arg, slashed, ok := splitCommand(text, bare)
+	if !ok {
+		return "", "", false
+	}
+
+	takesArg, known := commandTakesArg[name]
+	switch {
+	case !known:
+		return "", "", false
+	case arg != "" && !takesArg:
+		return "", "", false
+	case !slashed && strings.ContainsAny(arg, " \t\n") && !hasSubcommand(name, arg):
+		// "model the revenue forecast" is a prompt, not a command
+		return "", "", false
+	}
+	return name, arg, true
+}
+
+// parseCommand parses a built-in command or a prompt command. Bare prompt commands
+// take several words only if they declare arguments.
+func (a *App) parseCommand(text string, bare bool) (name, arg string, ok bool) {
+	if name, arg, ok = parseCommand(text, bare); ok {
+		return name, arg, true
+	}
+
+	name, arg, slashed, ok := splitCommand(text, bare)
+	if !ok {
+		return "", "", false
+	}
+	cmd, known := a.command(name)
+	switch {
+	case !known:
+		return "", "", false
+	case !slashed && strings.ContainsAny(arg, " \t\n") && len(cmd.Args) == 0:
+		return "", "", false
+	}
+	return name, arg, true
+}
+
+// splitCommand splits a message like "/model sonnet" into the lowercased command name
+// and argument, reporting whether it was slashed. Without bare, a slash is required.
+func splitCommand(text string, bare bool) (name, arg string, slashed, ok bool) {
+	text = strings.TrimSpace(text)
+	slashed = strings.HasPrefix(text, "/")
+	if !slashed && !bare {
+		return "", "", false, false
+	}
+
+	name, arg, _ = strings.Cut(strings.TrimPrefix(text, "/"), " ")
+	arg = strings.TrimSpace(arg)
+
+	// Telegram appends the bot username in groups: /status@MyBot
+	name, _, _ = strings.Cut(name, "@")
+	return strings.ToLower(name), arg, slashed, name != ""
+}
+
+// hasSubcommand reports whether arg starts with one of the command's subcommands
+func hasSubcommand(name, arg string) bool {
+	first, _, _ := strings.Cut(arg, " ")
+	for _, sub := range commandSubcommands[name] {
+		if strings.EqualFold(first, sub) {
+			return true
+		}
+	}
+	return false
+}
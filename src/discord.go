@@ -0,0 +1,288 @@
+// Package main provides the Discord adapter.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gpng/obsidian-pa/src/render"
+)
+
+// DiscordConfig holds Discord-specific configuration
+type DiscordConfig struct {
+	Token          string
+	AllowedUserIDs []string // The first user's direct chat is the home for /handoff
+	ChannelID      string   // Server channel the bot also answers in (empty = direct messages only)
+	APIURL         string   // REST API base URL (empty = DefaultDiscordAPIURL)
+}
+
+// DefaultDiscordAPIURL is the Discord REST API the bot talks to
+const DefaultDiscordAPIURL = "https://discord.com/api/v10"
+
+// maxDiscordLength is the number of characters Discord accepts in one message
+const maxDiscordLength = 2000
+
+// maxDiscordRetries is how often a rate-limited request is retried
+const maxDiscordRetries = 3
+
+// runDiscordBot starts the Discord bot and routes its messages
+func runDiscordBot(discordConfig *DiscordConfig, app *App) {
+	platform := newDiscordPlatform(discordConfig)
+
+	log.Printf("[Discord] Bot is running and listening for messages (using %s)...", app.Registry.Chain("").Name())
+
+	if err := app.Serve(platform); err != nil {
+		log.Fatalf("[Discord] Stopped receiving messages: %v", err)
+	}
+}
+
+// discordPlatform is the Discord adapter, receiving messages over the gateway and
+// answering over the REST API
+type discordPlatform struct {
+	token          string
+	apiURL         string
+	allowedUserIDs []string
+	channelID      string
+	client         *http.Client
+	home           Conversation // Direct chat with the first allowed user (empty = couldn't be opened)
+}
+
+// newDiscordPlatform creates the Discord client and opens the first allowed user's direct chat
+func newDiscordPlatform(discordConfig *DiscordConfig) *discordPlatform {
+	d := &discordPlatform{
+		token:          discordConfig.Token,
+		apiURL:         strings.TrimSuffix(orDefault(discordConfig.APIURL, DefaultDiscordAPIURL), "/"),
+		allowedUserIDs: discordConfig.AllowedUserIDs,
+		channelID:      discordConfig.ChannelID,
+		client:         &http.Client{Timeout: 30 * time.Second},
+	}
+
+	// The direct chat with the allowed user is where /handoff from other platforms lands
+	if len(d.allowedUserIDs) > 0 {
+		var dm struct {
+			ID string `json:"id"`
+		}
+		if err := d.request(http.MethodPost, "/users/@me/channels", map[string]string{"recipient_id": d.allowedUserIDs[0]}, &dm); err != nil {
+			log.Printf("[Discord] Failed to open direct chat, handoff to Discord disabled: %v", err)
+		} else {
+			d.home = Conversation{Chat: dm.ID, Direct: true}
+		}
+	}
+	return d
+}
+
+// Name identifies the platform
+func (d *discordPlatform) Name() string {
+	return "discord"
+}
+
+// AllowedUsers returns the allowed users' IDs
+func (d *discordPlatform) AllowedUsers() []string {
+	return d.allowedUserIDs
+}
+
+// Home returns the direct chat with the first allowed user, if it could be opened
+func (d *discordPlatform) Home() (Conversation, bool) {
+	return d.home, d.home.Chat != ""
+}
+
+// Receive connects to the gateway and passes on direct messages and messages in the
+// configured channel, reconnecting until Discord refuses the bot
+func (d *discordPlatform) Receive(handle func(Message)) error {
+	var gateway struct {
+		URL string `json:"url"`
+	}
+	if err := d.request(http.MethodGet, "/gateway/bot", nil, &gateway); err != nil {
+		return err
+	}
+
+	g := newDiscordGateway(gateway.URL, d.token, d.intents())
+	return g.run(func(m discordMessage) {
+		if msg, ok := d.message(m); ok {
+			handle(msg)
+		}
+	})
+}
+
+// intents returns the gateway events the bot subscribes to. Reading messages in a server
+// channel needs the privileged Message Content intent, so it is only asked for then.
+func (d *discordPlatform) intents() int {
+	intents := discordIntentDirectMessages
+	if d.channelID != "" {
+		intents |= discordIntentGuildMessages | discordIntentMessageContent
+	}
+	return intents
+}
+
+// message converts a gateway message, reporting false for messages the bot doesn't answer
+func (d *discordPlatform) message(m discordMessage) (Message, bool) {
+	// Ignore the bot's own messages, other bots and system messages such as pins
+	if m.Author.Bot || (m.Type != discordMessageDefault && m.Type != discordMessageReply) {
+		return Message{}, false
+	}
+
+	// Answer direct messages, and in servers only the configured channel
+	direct := m.GuildID == ""
+	if !direct && (d.channelID == "" || m.ChannelID != d.channelID) {
+		return Message{}, false
+	}
+
+	// Each channel is a conversation; only the home user's direct chat can share sessions
+	msg := Message{
+		Conversation: Conversation{Chat: m.ChannelID, Direct: direct && len(d.allowedUserIDs) > 0 && m.Author.ID == d.allowedUserIDs[0]},
+		UserID:       m.Author.ID,
+		Sender:       orDefault(m.Author.GlobalName, m.Author.Username),
+		Text:         m.Content,
+		Sent:         m.Timestamp,
+	}
+	if m.ReferencedMessage != nil {
+		msg.ReplyTo = m.ReferencedMessage.Content
+	}
+	return msg, true
+}
+
+// Send sends a plain text message and returns its ID
+func (d *discordPlatform) Send(conv Conversation, text string) (string, error) {
+	var sent struct {
+		ID string `json:"id"`
+	}
+	err := d.request(http.MethodPost, "/channels/"+conv.Chat+"/messages", discordContent(text), &sent)
+	return sent.ID, err
+}
+
+// SendMarkdown sends Markdown in Discord's dialect, split to fit Discord's message limit
+func (d *discordPlatform) SendMarkdown(conv Conversation, md string) error {
+	// Discord renders the Markdown itself, so the rendered text is what's split
+	for _, chunk := range render.Chunk(render.DiscordMarkdown(md), maxDiscordLength, utf8.RuneCountInString) {
+		if _, err := d.Send(conv, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Edit replaces the text of a message
+func (d *discordPlatform) Edit(conv Conversation, id, text string) error {
+	return d.request(http.MethodPatch, "/channels/"+conv.Chat+"/messages/"+id, discordContent(text), nil)
+}
+
+// Delete deletes a message
+func (d *discordPlatform) Delete(conv Conversation, id string) error {
+	return d.request(http.MethodDelete, "/channels/"+conv.Chat+"/messages/"+id, nil, nil)
+}
+
+// Upload sends a file attachment with the caption as the message text
+func (d *discordPlatform) Upload(conv Conversation, name string, content []byte, caption string) error {
+	text, _ := render.Excerpt(render.DiscordMarkdown(caption), maxDiscordLength)
+	payload := discordContent(text)
+	payload["attachments"] = []map[string]any{{"id": 0, "filename": name}}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("payload_json", string(payloadJSON)); err != nil {
+		return err
+	}
+	file, err := form.CreateFormFile("files[0]", name)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+	return d.do(http.MethodPost, "/channels/"+conv.Chat+"/messages", form.FormDataContentType(), body.Bytes(), nil)
+}
+
+// request calls the REST API with an optional JSON body, decoding the response into out
+func (d *discordPlatform) request(method, path string, body, out any) error {
+	if body == nil {
+		return d.do(method, path, "", nil, out)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return d.do(method, path, "application/json", data, out)
+}
+
+// do calls the REST API, waiting out rate limits, and decodes the response into out
+func (d *discordPlatform) do(method, path, contentType string, body []byte, out any) error {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, d.apiURL+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bot "+d.token)
+		req.Header.Set("User-Agent", "DiscordBot (https://github.com/gpng/obsidian-pa, 1.0)")
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxDiscordRetries {
+			var limit struct {
+				RetryAfter float64 `json:"retry_after"` // Seconds
+			}
+			_ = json.Unmarshal(data, &limit)
+			log.Printf("[Discord] Rate limited, retrying in %.1fs", limit.RetryAfter)
+			time.Sleep(time.Duration(limit.RetryAfter * float64(time.Second)))
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("discord %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+		}
+		if out == nil || len(data) == 0 {
+			return nil
+		}
+		return json.Unmarshal(data, out)
+	}
+}
+
+// discordContent is a message body that doesn't ping anyone, even if the AI writes @everyone
+func discordContent(text string) map[string]any {
+	return map[string]any{"content": text, "allowed_mentions": map[string]any{"parse": []string{}}}
+}
+
+// discordGatewayURL adds the API version and encoding to a gateway URL
+func discordGatewayURL(gateway string) string {
+	u, err := url.Parse(gateway)
+	if err != nil {
+		return gateway
+	}
+	query := u.Query()
+	query.Set("v", "10")
+	query.Set("encoding", "json")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// discordFatalClose reports whether the gateway closed for a reason reconnecting can't fix,
+// such as an invalid token or intents that aren't enabled for the bot
+func discordFatalClose(code int) bool {
+	return slices.Contains([]int{4004, 4010, 4011, 4012, 4013, 4014}, code)
+}
@@ -0,0 +1,297 @@
+// Package main provides the Discord gateway connection, which delivers messages to the
+// Discord adapter over a WebSocket and keeps the session alive across reconnects.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Gateway intents the bot subscribes to
+const (
+	discordIntentGuildMessages  = 1 << 9
+	discordIntentDirectMessages = 1 << 12
+	discordIntentMessageContent = 1 << 15
+)
+
+// Gateway opcodes
+const (
+	discordOpDispatch       = 0
+	discordOpHeartbeat      = 1
+	discordOpIdentify       = 2
+	discordOpResume         = 6
+	discordOpReconnect      = 7
+	discordOpInvalidSession = 9
+	discordOpHello          = 10
+	discordOpHeartbeatAck   = 11
+)
+
+// Message types the bot answers
+const (
+	discordMessageDefault = 0
+	discordMessageReply   = 19
+)
+
+// maxDiscordBackoff caps the wait between reconnection attempts
+const maxDiscordBackoff = time.Minute
+
+// discordMessage is a message from a MESSAGE_CREATE event
+type discordMessage struct {
+	ID                string      `json:"id"`
+	ChannelID         string      `json:"channel_id"`
+	GuildID           string      `json:"guild_id"` // Empty for direct messages
+	Type              int         `json:"type"`
+	Content           string      `json:"content"`
+	Timestamp         time.Time   `json:"timestamp"`
+	Author            discordUser `json:"author"`
+	ReferencedMessage *struct {
+		Content string `json:"content"`
+	} `json:"referenced_message"`
+}
+
+// discordUser is the author of a message
+type discordUser struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name"`
+	Bot        bool   `json:"bot"`
+}
+
+// discordPayload is a gateway event
+type discordPayload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  *int64          `json:"s"`
+	T  string          `json:"t"`
+}
+
+// discordGateway is a connection to the Discord gateway that resumes its session
+// after disconnects, so no messages are missed
+type discordGateway struct {
+	url     string
+	token   string
+	intents int
+
+	// Session to resume after a reconnect (empty = identify afresh)
+	sessionID string
+	resumeURL string
+
+	mu    sync.Mutex // Guards conn writes and seq
+	conn  *websocket.Conn
+	seq   *int64
+	acked bool
+}
+
+// newDiscordGateway returns a gateway connection for a bot token
+func newDiscordGateway(url, token string, intents int) *discordGateway {
+	return &discordGateway{url: url, token: token, intents: intents}
+}
+
+// run connects and passes on messages, reconnecting with backoff until Discord
+// closes the connection for a reason reconnecting can't fix
+func (g *discordGateway) run(handle func(discordMessage)) error {
+	backoff := time.Second
+	for {
+		connected, err := g.connect(handle)
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) && discordFatalClose(closeErr.Code) {
+			return fmt.Errorf("gateway closed the connection: %d %s", closeErr.Code, closeErr.Text)
+		}
+		if connected {
+			backoff = time.Second
+		}
+		log.Printf("[Discord] Gateway disconnected, reconnecting in %s: %v", backoff, err)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, maxDiscordBackoff)
+	}
+}
+
+// connect runs one gateway connection until it drops, reporting whether it got as far
+// as receiving events
+func (g *discordGateway) connect(handle func(discordMessage)) (bool, error) {
+	url := g.url
+	if g.sessionID != "" && g.resumeURL != "" {
+		url = g.resumeURL
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(discordGatewayURL(url), nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// The gateway says hello with the heartbeat interval
+	var hello struct {
+		HeartbeatInterval int `json:"heartbeat_interval"` // Milliseconds
+	}
+	var payload discordPayload
+	if err := conn.ReadJSON(&payload); err != nil {
+		return false, err
+	}
+	if payload.Op != discordOpHello {
+		return false, fmt.Errorf("expected hello, got opcode %d", payload.Op)
+	}
+	if err := json.Unmarshal(payload.D, &hello); err != nil {
+		return false, err
+	}
+
+	g.mu.Lock()
+	g.conn, g.acked = conn, true
+	g.mu.Unlock()
+
+	if err := g.login(); err != nil {
+		return false, err
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go g.heartbeat(time.Duration(hello.HeartbeatInterval)*time.Millisecond, stop)
+
+	connected := false
+	for {
+		var payload discordPayload
+		if err := conn.ReadJSON(&payload); err != nil {
+			g.forgetSession(err)
+			return connected, err
+		}
+
+		switch payload.Op {
+		case discordOpDispatch:
+			connected = true
+			g.mu.Lock()
+			g.seq = payload.S
+			g.mu.Unlock()
+			g.dispatch(payload, handle)
+
+		case discordOpHeartbeat:
+			// The gateway asks for a heartbeat right away
+			if err := g.sendHeartbeat(); err != nil {
+				return connected, err
+			}
+
+		case discordOpHeartbeatAck:
+			g.mu.Lock()
+			g.acked = true
+			g.mu.Unlock()
+
+		case discordOpReconnect:
+			return connected, errors.New("gateway asked to reconnect")
+
+		case discordOpInvalidSession:
+			// The session can only be resumed if the gateway says so
+			var resumable bool
+			_ = json.Unmarshal(payload.D, &resumable)
+			if !resumable {
+				g.sessionID = ""
+			}
+			return connected, errors.New("gateway invalidated the session")
+		}
+	}
+}
+
+// login resumes the previous session, or identifies to start a new one
+func (g *discordGateway) login() error {
+	if g.sessionID != "" {
+		g.mu.Lock()
+		seq := g.seq
+		g.mu.Unlock()
+		return g.send(discordOpResume, map[string]any{"token": g.token, "session_id": g.sessionID, "seq": seq})
+	}
+	return g.send(discordOpIdentify, map[string]any{
+		"token":   g.token,
+		"intents": g.intents,
+		"properties": map[string]string{
+			"os":      runtime.GOOS,
+			"browser": "obsidian-pa",
+			"device":  "obsidian-pa",
+		},
+	})
+}
+
+// dispatch handles an event
+func (g *discordGateway) dispatch(payload discordPayload, handle func(discordMessage)) {
+	switch payload.T {
+	case "READY":
+		var ready struct {
+			SessionID        string      `json:"session_id"`
+			ResumeGatewayURL string      `json:"resume_gateway_url"`
+			User             discordUser `json:"user"`
+		}
+		if err := json.Unmarshal(payload.D, &ready); err != nil {
+			log.Printf("[Discord] Failed to parse READY event: %v", err)
+			return
+		}
+		g.sessionID, g.resumeURL = ready.SessionID, ready.ResumeGatewayURL
+		log.Printf("[Discord] Connected to the gateway as %s", ready.User.Username)
+
+	case "RESUMED":
+		log.Println("[Discord] Resumed gateway session")
+
+	case "MESSAGE_CREATE":
+		var m discordMessage
+		if err := json.Unmarshal(payload.D, &m); err != nil {
+			log.Printf("[Discord] Failed to parse message: %v", err)
+			return
+		}
+		handle(m)
+	}
+}
+
+// heartbeat keeps the connection alive, closing it if the gateway stops acknowledging
+func (g *discordGateway) heartbeat(interval time.Duration, stop chan struct{}) {
+	// The first heartbeat is jittered so reconnecting bots don't all beat at once
+	timer := time.NewTimer(time.Duration(rand.Float64() * float64(interval)))
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+
+		g.mu.Lock()
+		acked := g.acked
+		g.acked = false
+		g.mu.Unlock()
+		if !acked {
+			// A zombie connection; closing it makes connect reconnect and resume
+			log.Println("[Discord] Heartbeat not acknowledged, reconnecting")
+			g.conn.Close()
+			return
+		}
+		if err := g.sendHeartbeat(); err != nil {
+			return
+		}
+		timer.Reset(interval)
+	}
+}
+
+// sendHeartbeat sends a heartbeat with the last sequence number
+func (g *discordGateway) sendHeartbeat() error {
+	g.mu.Lock()
+	seq := g.seq
+	g.mu.Unlock()
+	return g.send(discordOpHeartbeat, seq)
+}
+
+// send writes an event to the gateway
+func (g *discordGateway) send(op int, d any) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.conn.WriteJSON(map[string]any{"op": op, "d": d})
+}
+
+// forgetSession drops the session if the gateway closed in a way that can't be resumed
+func (g *discordGateway) forgetSession(err error) {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) && (closeErr.Code == 4007 || closeErr.Code == 4009) {
+		g.sessionID = ""
+	}
+}
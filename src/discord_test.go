@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeDiscord serves the parts of the Discord REST API and gateway the adapter uses
+type fakeDiscord struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu       sync.Mutex
+	posts    []string // Content of sent messages, in order
+	edits    int
+	deletes  int
+	files    []string // Names of uploaded files
+	limited  int      // Requests to answer with 429 before accepting
+	identify map[string]any
+	events   []map[string]any // Dispatched after READY
+	beats    chan struct{}
+	nextID   int
+}
+
+func newFakeDiscord(t *testing.T) *fakeDiscord {
+	f := &fakeDiscord{t: t, beats: make(chan struct{}, 10)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// platform returns an adapter talking to the fake server
+func (f *fakeDiscord) platform(channelID string) *discordPlatform {
+	return newDiscordPlatform(&DiscordConfig{
+		Token:          "token",
+		AllowedUserIDs: []string{"owner", "teammate"},
+		ChannelID:      channelID,
+		APIURL:         f.server.URL + "/api",
+	})
+}
+
+func (f *fakeDiscord) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/gateway" {
+		f.gateway(w, r)
+		return
+	}
+	if got := r.Header.Get("Authorization"); got != "Bot token" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.limited > 0 {
+		f.limited--
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"retry_after": 0.01}`)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api")
+	switch {
+	case path == "/gateway/bot":
+		fmt.Fprintf(w, `{"url": %q}`, "ws"+strings.TrimPrefix(f.server.URL, "http")+"/gateway")
+	case path == "/users/@me/channels":
+		fmt.Fprint(w, `{"id": "dm-owner"}`)
+	case r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/"):
+		_, header, err := r.FormFile("files[0]")
+		if err != nil {
+			f.t.Errorf("upload without a file: %v", err)
+		} else {
+			f.files = append(f.files, header.Filename)
+		}
+		f.nextID++
+		fmt.Fprintf(w, `{"id": "%d"}`, f.nextID)
+	case r.Method == http.MethodPost:
+		var body struct {
+			Content string `json:"content"`
+		}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			f.t.Errorf("invalid message body %q: %v", data, err)
+		}
+		f.posts = append(f.posts, body.Content)
+		f.nextID++
+		fmt.Fprintf(w, `{"id": "%d"}`, f.nextID)
+	case r.Method == http.MethodPatch:
+		f.edits++
+		fmt.Fprint(w, `{}`)
+	case r.Method == http.MethodDelete:
+		f.deletes++
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// gateway says hello, records the identify, sends READY and the queued events, then
+// acknowledges heartbeats until the test closes it with an invalid-token code
+func (f *fakeDiscord) gateway(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		f.t.Errorf("upgrade: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(map[string]any{"op": discordOpHello, "d": map[string]any{"heartbeat_interval": 20}})
+	var identify struct {
+		Op int            `json:"op"`
+		D  map[string]any `json:"d"`
+	}
+	if err := conn.ReadJSON(&identify); err != nil || identify.Op != discordOpIdentify {
+		f.t.Errorf("expected identify, got %+v (%v)", identify, err)
+		return
+	}
+
+	f.mu.Lock()
+	f.identify = identify.D
+	events := f.events
+	f.mu.Unlock()
+
+	seq := 1
+	conn.WriteJSON(map[string]any{"op": discordOpDispatch, "s": seq, "t": "READY", "d": map[string]any{"session_id": "s1", "user": map[string]any{"username": "pa"}}})
+	for _, event := range events {
+		seq++
+		conn.WriteJSON(map[string]any{"op": discordOpDispatch, "s": seq, "t": "MESSAGE_CREATE", "d": event})
+	}
+
+	// Acknowledge heartbeats; after a few the test has seen enough
+	for beats := 0; beats < 3; beats++ {
+		var heartbeat struct {
+			Op int `json:"op"`
+		}
+		if err := conn.ReadJSON(&heartbeat); err != nil {
+			return
+		}
+		if heartbeat.Op == discordOpHeartbeat {
+			f.beats <- struct{}{}
+			conn.WriteJSON(map[string]any{"op": discordOpHeartbeatAck})
+		}
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4004, "Authentication failed"))
+}
+
+// sent returns the content of every message sent so far
+func (f *fakeDiscord) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.posts...)
+}
+
+// discordEvent is a MESSAGE_CREATE event from a user
+func discordEvent(id, channel, guild, author, content string) map[string]any {
+	return map[string]any{
+		"id":         id,
+		"channel_id": channel,
+		"guild_id":   guild,
+		"type":       discordMessageDefault,
+		"content":    content,
+		"timestamp":  "2026-10-17T09:30:00+00:00",
+		"author":     map[string]any{"id": author, "username": author},
+	}
+}
+
+func TestDiscordReceivesDirectAndChannelMessages(t *testing.T) {
+	f := newFakeDiscord(t)
+	bot := discordEvent("5", "dm-owner", "", "helper", "beep")
+	bot["author"] = map[string]any{"id": "helper", "bot": true}
+	reply := discordEvent("6", "team", "guild", "teammate", "and this?")
+	reply["type"] = discordMessageReply
+	reply["referenced_message"] = map[string]any{"content": "earlier answer"}
+	f.events = []map[string]any{
+		discordEvent("1", "dm-owner", "", "owner", "hello"),
+		discordEvent("2", "other", "guild", "owner", "not this channel"),
+		bot,
+		reply,
+	}
+
+	d := f.platform("team")
+	var received []Message
+	err := d.Receive(func(msg Message) {
+		received = append(received, msg)
+	})
+	if err == nil || !strings.Contains(err.Error(), "4004") {
+		t.Errorf("Receive returned %v, want the fatal close", err)
+	}
+
+	if intents, _ := f.identify["intents"].(float64); int(intents)&discordIntentMessageContent == 0 {
+		t.Errorf("identified with intents %v, want message content for the channel", f.identify["intents"])
+	}
+	if len(received) != 2 {
+		t.Fatalf("received %+v, want the direct message and the channel reply", received)
+	}
+	if got := received[0]; got.Conversation.Chat != "dm-owner" || !got.Conversation.Direct || got.UserID != "owner" || got.Text != "hello" || got.Sent.IsZero() {
+		t.Errorf("direct message converted to %+v", got)
+	}
+	if got := received[1]; got.Conversation.Chat != "team" || got.Conversation.Direct || got.ReplyTo != "earlier answer" {
+		t.Errorf("channel reply converted to %+v", got)
+	}
+	if len(f.beats) == 0 {
+		t.Error("no heartbeats sent")
+	}
+}
+
+func TestDiscordDirectMessagesOnly(t *testing.T) {
+	f := newFakeDiscord(t)
+	f.events = []map[string]any{discordEvent("1", "team", "guild", "owner", "hello")}
+
+	var received []Message
+	f.platform("").Receive(func(msg Message) {
+		received = append(received, msg)
+	})
+	if len(received) != 0 {
+		t.Errorf("received %+v from a server channel without one configured", received)
+	}
+	if intents, _ := f.identify["intents"].(float64); int(intents) != discordIntentDirectMessages {
+		t.Errorf("identified with intents %v, want direct messages only", f.identify["intents"])
+	}
+}
+
+func TestDiscordSplitsLongResponses(t *testing.T) {
+	f := newFakeDiscord(t)
+	d := f.platform("")
+	if home, ok := d.Home(); !ok || home.Chat != "dm-owner" {
+		t.Errorf("home is %+v, want the owner's direct chat", home)
+	}
+
+	var md strings.Builder
+	md.WriteString("# Report\n\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&md, "Paragraph %d with some *emphasis* and words to fill the line.\n\n", i)
+	}
+	md.WriteString("```go\n")
+	for i := 0; i < 80; i++ {
+		fmt.Fprintf(&md, "fmt.Println(\"line %d of a long listing\")\n", i)
+	}
+	md.WriteString("```\n\n| a | b |\n|---|---|\n| 1 | 2 |\n")
+
+	f.limited = 1 // The first request is rate limited and retried
+	if err := d.SendMarkdown(d.home, md.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := f.sent()
+	if len(sent) < 3 {
+		t.Fatalf("sent %d messages, want the response split", len(sent))
+	}
+	for i, part := range sent {
+		if n := utf8.RuneCountInString(part); n > maxDiscordLength {
+			t.Errorf("part %d has %d characters", i, n)
+		}
+		if strings.Count(part, "```")%2 != 0 {
+			t.Errorf("part %d has an unclosed code block:\n%s", i, part)
+		}
+	}
+	if last := sent[len(sent)-1]; !strings.Contains(last, "```\na │ b") {
+		t.Errorf("table was not laid out in a code block:\n%s", last)
+	}
+}
+
+func TestDiscordRoutesThroughApp(t *testing.T) {
+	f := newFakeDiscord(t)
+	f.events = []map[string]any{
+		discordEvent("1", "dm-stranger", "", "stranger", "hello"),
+		discordEvent("2", "dm-owner", "", "owner", "hello"),
+	}
+	app, echo := newTestApp(t)
+
+	d := f.platform("")
+	go app.Serve(d)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(strings.Join(f.sent(), "\n"), "echo: hello") {
+		if time.Now().After(deadline) {
+			t.Fatalf("no response; sent %q", f.sent())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The indicator is sent, then deleted once the response arrives
+	if sent := f.sent(); sent[0] != "🧠 Processing..." {
+		t.Errorf("sent %q, want the indicator first", sent)
+	}
+	f.mu.Lock()
+	deletes := f.deletes
+	f.mu.Unlock()
+	if deletes != 1 {
+		t.Errorf("deleted %d messages, want the indicator", deletes)
+	}
+	if len(echo.requests) != 1 {
+		t.Errorf("ran %d prompts, want only the allowed user's", len(echo.requests))
+	}
+	if !app.Sessions.Get("discord:dm-owner").Active() {
+		t.Error("session was not recorded for the direct chat")
+	}
+}
+
+func TestDiscordUpload(t *testing.T) {
+	f := newFakeDiscord(t)
+	d := f.platform("")
+
+	if err := d.Upload(d.home, "Report.md", []byte("# Report\n"), "📎 **Report** · 1 words"); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.files) != 1 || f.files[0] != "Report.md" {
+		t.Errorf("uploaded %q, want Report.md", f.files)
+	}
+}
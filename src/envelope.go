@@ -0,0 +1,145 @@
+// Package main provides the prompt envelope: context about the message added around each prompt.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultPromptEnvelope is the envelope template used unless PROMPT_ENVELOPE points to another one
+const DefaultPromptEnvelope = `<context>
+Current time: {{.Now.Format "Monday, 2 January 2006 15:04"}} ({{.Timezone}})
+Platform: {{.Platform}}
+{{- if .Sender}}
+From: {{.Sender}}{{end}}
+{{- if not .Sent.IsZero}}
+Sent: {{.Sent.Format "2006-01-02 15:04"}}{{end}}
+{{- if .ReplyTo}}
+In reply to:
+{{.ReplyTo}}{{end}}
+</context>
+
+{{.Prompt}}`
+
+// messageMeta describes the chat message a prompt came from
+type messageMeta struct {
+	Platform string    // e.g. "Telegram"
+	Sender   string    // Display name of the sender
+	Sent     time.Time // When the message was sent
+	ReplyTo  string    // Text of the message it replies to
+	AsFile   bool      // The sender asked for the response as a file
+}
+
+// envelopeData is what the envelope template can use
+type envelopeData struct {
+	messageMeta
+	Now      time.Time // Current time in the configured timezone
+	Timezone string    // e.g. "Europe/Berlin, UTC+02:00"
+	Prompt   string    // The user's message
+}
+
+// PromptEnvelope wraps prompts in a template. The template is read from a file if one
+// is set, and re-read whenever it changes, so it can be tuned without a rebuild.
+type PromptEnvelope struct {
+	Path     string         // Template file ("" = DefaultPromptEnvelope)
+	Location *time.Location // Timezone for dates (nil = local)
+
+	mu       sync.Mutex
+	tmpl     *template.Template
+	modified time.Time
+}
+
+// Wrap renders the envelope around a prompt, falling back to the bare prompt on error
+func (e *PromptEnvelope) Wrap(prompt string, meta messageMeta) string {
+	if e == nil {
+		return prompt
+	}
+
+	tmpl, err := e.template()
+	if err != nil {
+		log.Printf("Failed to load prompt envelope %s: %v", e.Path, err)
+	}
+	if tmpl == nil {
+		tmpl = template.Must(template.New("envelope").Parse(DefaultPromptEnvelope))
+	}
+
+	loc := e.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	now := time.Now().In(loc)
+	if !meta.Sent.IsZero() {
+		meta.Sent = meta.Sent.In(loc)
+	}
+
+	var b strings.Builder
+	err = tmpl.Execute(&b, envelopeData{
+		messageMeta: meta,
+		Now:         now,
+		Timezone:    timezoneName(loc, now),
+		Prompt:      prompt,
+	})
+	if err != nil {
+		log.Printf("Failed to render prompt envelope: %v", err)
+		return prompt
+	}
+	return b.String()
+}
+
+// template returns the parsed template, re-reading the file if it changed.
+// If the file can't be loaded, the last template that could is returned with the error.
+func (e *PromptEnvelope) template() (*template.Template, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.Path == "" {
+		if e.tmpl == nil {
+			e.tmpl = template.Must(template.New("envelope").Parse(DefaultPromptEnvelope))
+		}
+		return e.tmpl, nil
+	}
+
+	info, err := os.Stat(e.Path)
+	if err != nil {
+		return e.tmpl, err
+	}
+	if e.tmpl != nil && info.ModTime().Equal(e.modified) {
+		return e.tmpl, nil
+	}
+
+	text, err := os.ReadFile(e.Path)
+	if err != nil {
+		return e.tmpl, err
+	}
+	tmpl, err := template.New("envelope").Parse(string(text))
+	if err != nil {
+		return e.tmpl, err
+	}
+	e.tmpl, e.modified = tmpl, info.ModTime()
+	log.Printf("Loaded prompt envelope from %s", e.Path)
+	return tmpl, nil
+}
+
+// timezoneName describes a timezone by name and UTC offset
+func timezoneName(loc *time.Location, now time.Time) string {
+	_, offset := now.Zone()
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	utc := fmt.Sprintf("UTC%s%02d:%02d", sign, offset/3600, offset%3600/60)
+
+	name := loc.String()
+	if name == "Local" {
+		name = os.Getenv("TZ")
+	}
+	if name == "" || name == "UTC" {
+		return utc
+	}
+	return name + ", " + utc
+}
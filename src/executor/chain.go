@@ -0,0 +1,86 @@
+// Package executor provides a fallback chain across AI CLI executors.
+package executor
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// Sessions maps executor names to their session IDs, so each backend
+// resumes its own conversation after a fallback
+type Sessions map[string]string
+
+// Chain runs executors in order, falling back to the next one on transient failures
+type Chain struct {
+	executors []Executor
+}
+
+// NewChain creates a chain; the first executor is the primary
+func NewChain(executors ...Executor) *Chain {
+	return &Chain{executors: executors}
+}
+
+// Name returns the executor names in fallback order (for logging)
+func (c *Chain) Name() string {
+	names := make([]string, len(c.executors))
+	for i, e := range c.executors {
+		names[i] = e.Name()
+	}
+	return strings.Join(names, " → ")
+}
+
+// Primary returns the first executor in the chain
+func (c *Chain) Primary() Executor {
+	return c.executors[0]
+}
+
+// EnforcesAppend reports whether every executor in the chain enforces append mode,
+// so a fallback can't edit notes either
+func (c *Chain) EnforcesAppend() bool {
+	for _, e := range c.executors {
+		if !EnforcesAppend(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetStartPrompt returns the primary executor's /start prompt
+func (c *Chain) GetStartPrompt() string {
+	return c.Primary().GetStartPrompt()
+}
+
+// Execute runs the request on each executor in turn until one succeeds or fails
+// permanently, resuming each executor's own session from sessions. Executors without
+// one fork their session from forks, if it has one (e.g. after /session fork).
+// The request's model only applies to the primary; fallbacks use their own default.
+func (c *Chain) Execute(ctx context.Context, req Request, sessions, forks Sessions, onEvent EventHandler) *Result {
+	var fallbacks []string
+	var res *Result
+
+	for i, e := range c.executors {
+		req.SessionID, req.Fork = sessions[e.Name()], false
+		if req.SessionID == "" && forks[e.Name()] != "" {
+			req.SessionID, req.Fork = forks[e.Name()], true
+		}
+		if i > 0 {
+			req.Model = ""
+		}
+
+		res = e.Execute(ctx, req, onEvent)
+		res.Executor = e.Name()
+		res.Fallbacks = fallbacks
+
+		if !res.Failed() || !res.ErrorKind.Transient() || i == len(c.executors)-1 {
+			return res
+		}
+
+		next := c.executors[i+1].Name()
+		log.Printf("%s failed (%s), falling back to %s", e.Name(), res.ErrorKind, next)
+		onEvent.emit(Event{Type: EventFallback, Text: next})
+		fallbacks = append(fallbacks, e.Name())
+	}
+
+	return res
+}
@@ -2,12 +2,10 @@
 package executor
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"log"
-	"os"
-	"os/exec"
-	"strings"
+	"time"
 )
 
 // Claude implements the Executor interface for Claude CLI
@@ -15,10 +13,41 @@ type Claude struct {
 	config *Config
 }
 
-// claudeResponse represents the JSON response from Claude CLI
-type claudeResponse struct {
-	SessionID string `json:"session_id"`
-	Result    string `json:"result"`
+// claudeStreamEvent represents an event from Claude CLI stream-json output
+type claudeStreamEvent struct {
+	Type      string `json:"type"`
+	Subtype   string `json:"subtype,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	// For system init events
+	Model string `json:"model,omitempty"`
+	// For assistant events
+	Message *claudeMessage `json:"message,omitempty"`
+	// For result events
+	Result       string       `json:"result,omitempty"`
+	IsError      bool         `json:"is_error,omitempty"`
+	TotalCostUSD float64      `json:"total_cost_usd,omitempty"`
+	Usage        *claudeUsage `json:"usage,omitempty"`
+}
+
+// claudeUsage is the token usage reported in a result event
+type claudeUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+}
+
+// claudeMessage is the API message wrapped in an assistant event
+type claudeMessage struct {
+	Content []claudeContent `json:"content"`
+}
+
+// claudeContent is a single content block (text or tool use) of an assistant message
+type claudeContent struct {
+	Type  string         `json:"type"`
+	Text  string         `json:"text,omitempty"`
+	Name  string         `json:"name,omitempty"`
+	Input map[string]any `json:"input,omitempty"`
 }
 
 // NewClaude creates a new Claude executor
@@ -31,57 +60,118 @@ func (c *Claude) Name() string {
 	return "Claude"
 }
 
-// Execute runs the Claude CLI with the given prompt and returns the output and session ID
-func (c *Claude) Execute(prompt string, sessionID string) (string, string) {
+// Execute runs the Claude CLI with the given prompt and returns the result
+func (c *Claude) Execute(ctx context.Context, req Request, onEvent EventHandler) *Result {
+	model := req.model(c.config)
 	args := []string{
-		"-p", prompt,
-		"--dangerously-skip-permissions",
+		"-p", withModeNotice(req.Prompt, req.Mode),
 		"--add-dir", c.config.VaultPath,
-		"--output-format", "json",
-		"--model", c.config.Model,
+		"--output-format", "stream-json", // Streaming JSON for live progress
+		"--verbose", // Required by stream-json in print mode
+		"--model", model,
 	}
 
+	// Restrict tools to the permission mode
+	args = append(args, claudePermissionArgs(req.Mode)...)
+
 	// Resume session if we have one
-	if sessionID != "" {
-		args = append(args, "--resume", sessionID)
+	if req.SessionID != "" {
+		args = append(args, "--resume", req.SessionID)
+		if req.Fork {
+			args = append(args, "--fork-session") // Continue in a new session ID
+		}
 	}
 
-	cmd := exec.Command("claude", args...)
-	cmd.Dir = c.config.VaultPath
-	cmd.Env = append(os.Environ(), "ANTHROPIC_API_KEY="+c.config.APIKey)
+	cmd, runCtx, cancel := newCommand(ctx, c.config, "claude", args...)
+	defer cancel()
+	cmd.Env = childEnv(c.config, "ANTHROPIC_API_KEY="+c.config.APIKey)
 
-	output, err := cmd.CombinedOutput()
-	rawOutput := strings.TrimSpace(string(output))
+	res := &Result{Model: model}
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+	emit := res.track(onEvent)
 
-	if err != nil {
-		if rawOutput == "" {
-			rawOutput = err.Error()
+	var gotResult, isError bool
+
+	rawOutput, err := streamCommand(cmd, func(line []byte) {
+		var event claudeStreamEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			log.Printf("[Claude] Failed to parse JSON event: %v", err)
+			return
+		}
+		if event.SessionID != "" {
+			res.SessionID = event.SessionID
 		}
-		return fmt.Sprintf("❌ Error:\n%s", rawOutput), ""
+
+		switch event.Type {
+		case "system":
+			if event.Subtype == "init" {
+				emit(Event{Type: EventInit, SessionID: event.SessionID, Model: event.Model})
+			}
+		case "assistant":
+			if event.Message == nil {
+				return
+			}
+			for _, content := range event.Message.Content {
+				switch content.Type {
+				case "text":
+					emit(Event{Type: EventText, Text: content.Text})
+				case "tool_use":
+					emit(toolEvent(c.config.VaultPath, content.Name, content.Input))
+				}
+			}
+		case "result":
+			gotResult = true
+			isError = event.IsError
+			res.Response = event.Result
+			res.CostUSD = event.TotalCostUSD
+			if event.Usage != nil {
+				res.Usage = Usage{
+					InputTokens:      event.Usage.InputTokens,
+					OutputTokens:     event.Usage.OutputTokens,
+					CacheReadTokens:  event.Usage.CacheReadInputTokens,
+					CacheWriteTokens: event.Usage.CacheCreationInputTokens,
+				}
+			}
+			emit(Event{Type: EventResult, SessionID: event.SessionID, Text: event.Result})
+		}
+	})
+
+	// Report cancellation and timeouts rather than the killed process' output
+	if kind, aborted := abortKind(runCtx); aborted {
+		return res.fail(kind, runCtx.Err().Error())
 	}
 
-	// Try to parse JSON response
-	var resp claudeResponse
-	if err := json.Unmarshal([]byte(rawOutput), &resp); err != nil {
-		// If JSON parsing fails, return raw output (might be plain text on error)
-		log.Printf("[Claude] Failed to parse JSON response: %v", err)
-		if rawOutput == "" {
-			return "✅ Done (no output)", ""
+	if err != nil || isError {
+		if isError && res.Response != "" {
+			rawOutput = res.Response
+		}
+		if rawOutput == "" && err != nil {
+			rawOutput = err.Error()
 		}
-		return rawOutput, ""
+		log.Printf("[Claude] Run failed: %s", rawOutput)
+		return res.fail(classifyError(err, rawOutput), rawOutput)
 	}
 
-	// Extract result and session ID
-	result := resp.Result
-	if result == "" {
-		result = "✅ Done (no output)"
+	if !gotResult {
+		// No result event - the output was not the stream-json we asked for
+		log.Printf("[Claude] No result event in output")
+		return res.fail(ErrorParse, rawOutput)
 	}
 
-	return result, resp.SessionID
+	if res.Response == "" {
+		res.Response = "✅ Done (no output)"
+	}
+
+	return res
+}
+
+// ReportsCost reports that results carry the cost the CLI reports
+func (c *Claude) ReportsCost() bool {
+	return true
 }
 
 // GetStartPrompt returns the prompt used for the /start command
 func (c *Claude) GetStartPrompt() string {
 	return StartPrompt
 }
-
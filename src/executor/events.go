@@ -0,0 +1,117 @@
+// Package executor provides progress events published while an AI CLI runs.
+package executor
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// EventType identifies the kind of progress event
+type EventType string
+
+const (
+	EventInit     EventType = "init"     // Session started (SessionID and Model set)
+	EventText     EventType = "text"     // Assistant text (Text set)
+	EventToolUse  EventType = "tool_use" // Tool invoked (Tool, Action and Path set)
+	EventResult   EventType = "result"   // Run finished (Text holds the final response)
+	EventFallback EventType = "fallback" // Transient failure, retrying on the executor named in Text
+)
+
+// ToolAction is a CLI-independent classification of a tool call
+type ToolAction string
+
+const (
+	ActionRead   ToolAction = "read"
+	ActionWrite  ToolAction = "write"
+	ActionEdit   ToolAction = "edit"
+	ActionSearch ToolAction = "search"
+	ActionList   ToolAction = "list"
+	ActionShell  ToolAction = "shell"
+	ActionWeb    ToolAction = "web"
+	ActionOther  ToolAction = "other"
+)
+
+// Event is a progress update published while the AI CLI runs
+type Event struct {
+	Type      EventType
+	SessionID string
+	Model     string
+	Text      string
+	Tool      string     // Tool name as reported by the CLI
+	Action    ToolAction // Normalized tool action
+	Path      string     // File or directory the tool works on, relative to the vault when inside it
+}
+
+// EventHandler receives events as they happen. A nil handler discards them.
+type EventHandler func(Event)
+
+// emit calls the handler if one is set
+func (h EventHandler) emit(ev Event) {
+	if h != nil {
+		h(ev)
+	}
+}
+
+// toolActions maps Claude and Gemini CLI tool names to their actions
+var toolActions = map[string]ToolAction{
+	// Claude CLI
+	"Read":         ActionRead,
+	"NotebookRead": ActionRead,
+	"Write":        ActionWrite,
+	"Edit":         ActionEdit,
+	"MultiEdit":    ActionEdit,
+	"NotebookEdit": ActionEdit,
+	"Glob":         ActionSearch,
+	"Grep":         ActionSearch,
+	"LS":           ActionList,
+	"Bash":         ActionShell,
+	"WebFetch":     ActionWeb,
+	"WebSearch":    ActionWeb,
+	// Gemini CLI
+	"read_file":           ActionRead,
+	"read_many_files":     ActionRead,
+	"write_file":          ActionWrite,
+	"replace":             ActionEdit,
+	"glob":                ActionSearch,
+	"search_file_content": ActionSearch,
+	"list_directory":      ActionList,
+	"run_shell_command":   ActionShell,
+	"web_fetch":           ActionWeb,
+	"google_web_search":   ActionWeb,
+}
+
+// toolEvent builds a tool use event from a tool name and its raw input parameters
+func toolEvent(vaultPath, tool string, input map[string]any) Event {
+	action, ok := toolActions[tool]
+	if !ok {
+		action = ActionOther
+	}
+
+	// Tools name their target differently; take the first path-like parameter
+	var path string
+	for _, key := range []string{"file_path", "absolute_path", "notebook_path", "path", "dir_path"} {
+		if v, ok := input[key].(string); ok && v != "" {
+			path = v
+			break
+		}
+	}
+
+	return Event{
+		Type:   EventToolUse,
+		Tool:   tool,
+		Action: action,
+		Path:   vaultRelative(vaultPath, path),
+	}
+}
+
+// vaultRelative returns path relative to the vault, or unchanged if it lies outside it
+func vaultRelative(vaultPath, path string) string {
+	if path == "" || vaultPath == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	rel, err := filepath.Rel(vaultPath, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
@@ -1,11 +1,30 @@
 // Package executor provides a common interface for AI CLI executors.
 package executor
 
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// waitDelay bounds how long Wait blocks on output pipes after the CLI is killed
+const waitDelay = 5 * time.Second
+
+// maxLineSize bounds a single line of streamed CLI output (final results can be large)
+const maxLineSize = 16 * 1024 * 1024
+
 // Executor defines the interface for AI CLI executors (Claude, Gemini, etc.)
 type Executor interface {
-	// Execute runs the AI CLI with the given prompt and optional session ID.
-	// Returns the response text and the session ID (for session continuity).
-	Execute(prompt string, sessionID string) (response string, newSessionID string)
+	// Execute runs the AI CLI with the request's prompt and optional session ID.
+	// The run is aborted when ctx is done or the configured timeout elapses.
+	// Progress events are published to onEvent (may be nil) as they happen.
+	// Returns the outcome of the run; failures are classified in the result.
+	Execute(ctx context.Context, req Request, onEvent EventHandler) *Result
 
 	// GetStartPrompt returns the prompt used for the /start command.
 	GetStartPrompt() string
@@ -14,11 +33,112 @@ type Executor interface {
 	Name() string
 }
 
+// CostReporter is implemented by executors that report the cost of their runs
+type CostReporter interface {
+	ReportsCost() bool
+}
+
+// ReportsCost reports whether an executor sets Result.CostUSD. Runs of executors that
+// don't count nothing towards the budget caps.
+func ReportsCost(e Executor) bool {
+	reporter, ok := e.(CostReporter)
+	return ok && reporter.ReportsCost()
+}
+
 // Config holds common configuration for all executors
 type Config struct {
-	APIKey    string // API key for the AI service
-	VaultPath string // Path to the Obsidian vault
-	Model     string // Model to use
+	APIKey    string        // API key for the AI service
+	VaultPath string        // Path to the Obsidian vault
+	Model     string        // Model to use
+	Timeout   time.Duration // Maximum duration of a single run (0 = no limit)
+	BaseURL   string        // API endpoint for HTTP executors (e.g. http://localhost:11434/v1)
+	StateDir  string        // Directory for executor-owned state (e.g. HTTP conversation history)
+	Env       []string      // Environment variables passed through to the CLI (see DefaultEnv)
+	HomeDir   string        // Dedicated HOME of the CLI (empty = the bot's HOME)
+	HomeLinks []string      // Entries of the bot's HOME linked into HomeDir (e.g. ".gemini")
+	Limits    Limits        // Resource limits of the CLI process
+}
+
+// Request describes a single run
+type Request struct {
+	Prompt    string         // Prompt sent to the AI
+	SessionID string         // Session to resume (empty = new session)
+	Model     string         // Model for this run (empty = Config.Model)
+	Mode      PermissionMode // What the run may change (empty = read-only)
+	Fork      bool           // Branch SessionID into a new session, leaving it unchanged
+}
+
+// model returns the model to run, falling back to the configured one
+func (r Request) model(config *Config) string {
+	if r.Model != "" {
+		return r.Model
+	}
+	return config.Model
+}
+
+// newCommand builds a CLI command bound to ctx and the configured timeout, with the
+// allowlisted environment, dedicated HOME and resource limits of the executor.
+// The returned cancel func must be called once the command has finished.
+func newCommand(ctx context.Context, config *Config, name string, args ...string) (*exec.Cmd, context.Context, context.CancelFunc) {
+	var cancel context.CancelFunc
+	if config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	prepareHome(config)
+	name, args = limitCommand(config.Limits, name, args)
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = config.VaultPath
+	cmd.Env = childEnv(config)
+	cmd.WaitDelay = waitDelay
+	setProcessGroup(cmd)
+
+	return cmd, ctx, cancel
+}
+
+// streamCommand runs cmd, passing each JSON line of its stdout to handle as it arrives.
+// Returns the remaining output (stderr and non-JSON stdout lines) for error reporting.
+func streamCommand(cmd *exec.Cmd, handle func(line []byte)) (string, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	var plain strings.Builder
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		// Keep non-JSON lines (e.g. "Loaded cached credentials.") for error reporting
+		if line[0] != '{' {
+			plain.Write(line)
+			plain.WriteByte('\n')
+			continue
+		}
+
+		handle(line)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Failed to read CLI output: %v", err)
+		// Drain the pipe so the CLI doesn't block on a full buffer
+		io.Copy(io.Discard, stdout)
+	}
+
+	err = cmd.Wait()
+	return strings.TrimSpace(stderr.String() + "\n" + plain.String()), err
 }
 
 // StartPrompt is the shared prompt used for the /start command across all executors
@@ -29,12 +149,4 @@ Then, help me start my day by:
 2. Checking for recent notes or updates
 3. Suggesting what I should focus on today
 
-Provide a concise daily briefing.
-
-IMPORTANT: Format your response using Slack's mrkdwn syntax:
-- Bold: *text* (single asterisks)
-- Italic: _text_
-- Code: ` + "`code`" + `
-- Bullet lists: Use plain - or • characters
-- Do NOT use ** for bold or standard markdown`
-
+Provide a concise daily briefing, formatted in standard Markdown.`
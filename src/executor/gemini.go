@@ -2,13 +2,12 @@
 package executor
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
-	"os/exec"
 	"strings"
+	"time"
 )
 
 // Gemini implements the Executor interface for Gemini CLI
@@ -24,13 +23,24 @@ type geminiStreamEvent struct {
 	// For message events
 	Role    string `json:"role,omitempty"`
 	Content string `json:"content,omitempty"`
+	// For tool_use events
+	ToolName   string         `json:"tool_name,omitempty"`
+	Parameters map[string]any `json:"parameters,omitempty"`
 	// For result events
-	Response string `json:"response,omitempty"`
+	Response string       `json:"response,omitempty"`
+	Stats    *geminiStats `json:"stats,omitempty"`
 	// For error events
 	Error   string `json:"error,omitempty"`
 	Message string `json:"message,omitempty"`
 }
 
+// geminiStats is the token usage reported in a result event
+type geminiStats struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	Cached       int `json:"cached"`
+}
+
 // NewGemini creates a new Gemini executor
 func NewGemini(config *Config) *Gemini {
 	return &Gemini{config: config}
@@ -41,108 +51,148 @@ func (g *Gemini) Name() string {
 	return "Gemini"
 }
 
-// Execute runs the Gemini CLI with the given prompt and returns the output and session ID
-func (g *Gemini) Execute(prompt string, sessionID string) (string, string) {
+// Execute runs the Gemini CLI with the given prompt and returns the result
+func (g *Gemini) Execute(ctx context.Context, req Request, onEvent EventHandler) *Result {
+	model := req.model(g.config)
 	args := []string{
-		"-p", prompt,
-		"--yolo",                                    // Auto-accept all permissions
+		"-p", withModeNotice(req.Prompt, req.Mode),
 		"--include-directories", g.config.VaultPath, // Add vault as context
-		"--output-format", "stream-json",            // Streaming JSON to capture session_id
+		"--output-format", "stream-json", // Streaming JSON for session_id and live progress
 	}
 
+	// Restrict tools to the permission mode
+	args = append(args, geminiPermissionArgs(req.Mode)...)
+
 	// Only pass model flag if not "auto" or empty (let Gemini CLI use its default)
-	if g.config.Model != "" && g.config.Model != "auto" {
-		args = append(args, "-m", g.config.Model)
+	if model != "" && model != "auto" {
+		args = append(args, "-m", model)
 	}
 
-	// Resume session if we have one
-	if sessionID != "" {
-		args = append(args, "--resume", sessionID)
+	// Resume session if we have one. Gemini CLI can't branch a session,
+	// so a fork starts a new one rather than continuing the original.
+	if req.SessionID != "" && !req.Fork {
+		args = append(args, "--resume", req.SessionID)
 	}
 
-	cmd := exec.Command("gemini", args...)
-	cmd.Dir = g.config.VaultPath
+	cmd, runCtx, cancel := newCommand(ctx, g.config, "gemini", args...)
+	defer cancel()
 
-	// Set API key environment variable if provided
+	// Set API key environment variable if provided (otherwise OAuth from HOME/.gemini)
 	if g.config.APIKey != "" {
-		cmd.Env = append(os.Environ(), "GEMINI_API_KEY="+g.config.APIKey)
-	} else {
-		cmd.Env = os.Environ() // Use existing environment (OAuth-based auth)
+		cmd.Env = childEnv(g.config, "GEMINI_API_KEY="+g.config.APIKey)
 	}
 
-	output, err := cmd.CombinedOutput()
-	rawOutput := strings.TrimSpace(string(output))
+	res := &Result{Model: model}
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+
+	// Parse streaming JSON (newline-delimited JSON) as it arrives
+	stream := &geminiStream{vaultPath: g.config.VaultPath, result: res, onEvent: res.track(onEvent)}
+	rawOutput, err := streamCommand(cmd, stream.handle)
+	res.SessionID = stream.sessionID
+
+	// Report cancellation and timeouts rather than the killed process' output
+	if kind, aborted := abortKind(runCtx); aborted {
+		return res.fail(kind, runCtx.Err().Error())
+	}
 
 	if err != nil {
+		if stream.errMsg != "" {
+			rawOutput = stream.errMsg
+		}
 		if rawOutput == "" {
 			rawOutput = err.Error()
 		}
-		return fmt.Sprintf("❌ Error:\n%s", rawOutput), ""
+		log.Printf("[Gemini] Run failed: %s", rawOutput)
+		return res.fail(classifyError(err, rawOutput), rawOutput)
+	}
+
+	if stream.errMsg != "" {
+		log.Printf("[Gemini] Run failed: %s", stream.errMsg)
+		return res.fail(classifyError(nil, stream.errMsg), stream.errMsg)
+	}
+
+	if stream.events == 0 && rawOutput != "" {
+		// Output but no events - the output was not the stream-json we asked for
+		log.Printf("[Gemini] No JSON events in output")
+		return res.fail(ErrorParse, rawOutput)
 	}
 
-	// Parse streaming JSON (newline-delimited JSON)
-	return parseStreamOutput(rawOutput)
+	res.Response = stream.response()
+	return res
 }
 
-// parseStreamOutput parses newline-delimited JSON events from Gemini CLI
-// Returns thinking steps and final response with clear separation
-func parseStreamOutput(output string) (string, string) {
-	var sessionID string
-	var thinkingSteps []string
-	var finalResult string
-
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
+// geminiStream accumulates newline-delimited JSON events from Gemini CLI,
+// publishing progress events as they arrive
+type geminiStream struct {
+	vaultPath string
+	result    *Result
+	onEvent   EventHandler
+
+	events        int
+	sessionID     string
+	thinkingSteps []string
+	finalResult   string
+	errMsg        string
+}
 
-		// Skip non-JSON lines (e.g., "YOLO mode is enabled...", "Loaded cached credentials.")
-		if !strings.HasPrefix(line, "{") {
-			continue
+// handle parses a single stream-json event line
+func (s *geminiStream) handle(line []byte) {
+	var event geminiStreamEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		log.Printf("[Gemini] Failed to parse JSON event: %v", err)
+		return
+	}
+	s.events++
+
+	switch event.Type {
+	case "init":
+		// Capture session ID from init event
+		s.sessionID = event.SessionID
+		s.onEvent.emit(Event{Type: EventInit, SessionID: event.SessionID, Model: event.Model})
+	case "message":
+		// Collect assistant messages as thinking steps
+		if event.Role == "assistant" && event.Content != "" {
+			s.thinkingSteps = append(s.thinkingSteps, event.Content)
+			s.onEvent.emit(Event{Type: EventText, Text: event.Content})
 		}
-
-		var event geminiStreamEvent
-		if err := json.Unmarshal([]byte(line), &event); err != nil {
-			log.Printf("[Gemini] Failed to parse JSON event: %v", err)
-			continue
+	case "tool_use":
+		s.onEvent.emit(toolEvent(s.vaultPath, event.ToolName, event.Parameters))
+	case "result":
+		// Final result - this is the complete response
+		if event.Response != "" {
+			s.finalResult = event.Response
 		}
-
-		switch event.Type {
-		case "init":
-			// Capture session ID from init event
-			sessionID = event.SessionID
-		case "message":
-			// Collect assistant messages as thinking steps
-			if event.Role == "assistant" && event.Content != "" {
-				thinkingSteps = append(thinkingSteps, event.Content)
-			}
-		case "result":
-			// Final result - this is the complete response
-			if event.Response != "" {
-				finalResult = event.Response
-			}
-		case "error":
-			// Handle errors
-			errMsg := event.Error
-			if errMsg == "" {
-				errMsg = event.Message
-			}
-			if errMsg != "" {
-				return fmt.Sprintf("❌ Error: %s", errMsg), sessionID
+		if event.Stats != nil {
+			s.result.Usage = Usage{
+				InputTokens:     event.Stats.InputTokens,
+				OutputTokens:    event.Stats.OutputTokens,
+				CacheReadTokens: event.Stats.Cached,
 			}
 		}
+		s.onEvent.emit(Event{Type: EventResult, SessionID: s.sessionID, Text: event.Response})
+	case "error":
+		// Keep the first error; it is reported instead of the response
+		errMsg := event.Error
+		if errMsg == "" {
+			errMsg = event.Message
+		}
+		if s.errMsg == "" {
+			s.errMsg = errMsg
+		}
 	}
+}
 
+// response returns thinking steps and final response with clear separation
+func (s *geminiStream) response() string {
 	// Build formatted response
 	var result strings.Builder
 
 	// Add thinking section if we have both thinking steps AND a final result
 	// (If no final result, thinking steps ARE the response)
-	if len(thinkingSteps) > 0 && finalResult != "" {
+	if len(s.thinkingSteps) > 0 && s.finalResult != "" {
 		result.WriteString("_🧠 Thinking:_\n")
-		for i, step := range thinkingSteps {
+		for i, step := range s.thinkingSteps {
 			// Add step number for clarity
 			result.WriteString(fmt.Sprintf("%d. %s\n", i+1, step))
 		}
@@ -150,23 +200,22 @@ func parseStreamOutput(output string) (string, string) {
 	}
 
 	// Add final response
-	if finalResult != "" {
-		result.WriteString("*📋 Response:*\n")
-		result.WriteString(finalResult)
-	} else if len(thinkingSteps) > 0 {
+	if s.finalResult != "" {
+		result.WriteString("**📋 Response:**\n")
+		result.WriteString(s.finalResult)
+	} else if len(s.thinkingSteps) > 0 {
 		// No final result, so concatenate thinking steps as the response
-		for _, step := range thinkingSteps {
+		for _, step := range s.thinkingSteps {
 			result.WriteString(step)
 		}
 	} else {
 		result.WriteString("✅ Done (no output)")
 	}
 
-	return result.String(), sessionID
+	return result.String()
 }
 
 // GetStartPrompt returns the prompt used for the /start command
 func (g *Gemini) GetStartPrompt() string {
 	return StartPrompt
 }
-
@@ -0,0 +1,324 @@
+// Package executor provides an executor for OpenAI-compatible chat-completions APIs.
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gpng/obsidian-pa/src/jsonfile"
+)
+
+// maxToolRounds bounds the model/tool round trips of a single run
+const maxToolRounds = 25
+
+// openAISystemPrompt tells the model how to work with the vault tools
+const openAISystemPrompt = `You are a personal assistant managing the user's Obsidian vault of Markdown notes.
+Use the provided tools to read, search, list, write and append to notes. All paths are relative to the vault root.
+If AGENT.md exists in the vault root, read it first and follow its instructions.
+Keep answers concise. Only change notes when the user asks you to.`
+
+// OpenAI implements the Executor interface for any OpenAI-compatible
+// chat-completions endpoint (e.g. llama.cpp or Ollama), running its own tool loop
+// with vault tools confined to Config.VaultPath
+type OpenAI struct {
+	config *Config
+	client *http.Client
+}
+
+// openAIMessage is a chat message in the request and response
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openAIToolCall is a function call requested by the model
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// openAIRequest is the chat-completions request body
+type openAIRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Tools    []toolSpec      `json:"tools"`
+}
+
+// openAIResponse is the chat-completions response body
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// openAIStatusError is a non-2xx response from the API
+type openAIStatusError struct {
+	Status int
+	Body   string
+}
+
+func (e *openAIStatusError) Error() string {
+	return fmt.Sprintf("API returned %d: %s", e.Status, e.Body)
+}
+
+// sessionIDPattern validates session IDs before they are used as file names
+var sessionIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// NewOpenAI creates a new OpenAI-compatible executor
+func NewOpenAI(config *Config) *OpenAI {
+	return &OpenAI{config: config, client: &http.Client{}}
+}
+
+// Name returns the executor name for logging
+func (o *OpenAI) Name() string {
+	return "OpenAI"
+}
+
+// Execute runs the tool loop against the chat-completions endpoint and returns the result.
+// The conversation is stored under Config.StateDir so the session can be resumed.
+func (o *OpenAI) Execute(ctx context.Context, req Request, onEvent EventHandler) *Result {
+	if o.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.config.Timeout)
+		defer cancel()
+	}
+
+	model := req.model(o.config)
+	res := &Result{Model: model}
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+	emit := res.track(onEvent)
+
+	tools, err := newVaultTools(o.config.VaultPath, req.Mode)
+	if err != nil {
+		return res.fail(ErrorUnknown, err.Error())
+	}
+
+	// Resume the stored conversation, or start a new one
+	messages, sessionID, err := o.loadSession(req.SessionID)
+	if err == nil && req.Fork {
+		// Continue a copy of the conversation under a new ID
+		sessionID, err = newSessionID()
+	}
+	if err != nil {
+		log.Printf("[OpenAI] Failed to load session %s: %v", req.SessionID, err)
+		return res.fail(ErrorUnknown, err.Error())
+	}
+	res.SessionID = sessionID
+	messages = append(messages, openAIMessage{Role: "user", Content: req.Prompt})
+	emit(Event{Type: EventInit, SessionID: sessionID, Model: model})
+
+	for round := 0; round < maxToolRounds; round++ {
+		resp, err := o.complete(ctx, model, messages, tools.specs())
+		if kind, aborted := abortKind(ctx); aborted {
+			return res.fail(kind, ctx.Err().Error())
+		}
+		if err != nil {
+			log.Printf("[OpenAI] Request failed: %v", err)
+			return res.fail(classifyHTTPError(err), err.Error())
+		}
+
+		res.Usage.InputTokens += resp.Usage.PromptTokens
+		res.Usage.OutputTokens += resp.Usage.CompletionTokens
+		if resp.Model != "" {
+			res.Model = resp.Model
+		}
+
+		msg := resp.Choices[0].Message
+		messages = append(messages, msg)
+		if msg.Content != "" {
+			emit(Event{Type: EventText, Text: msg.Content})
+		}
+
+		// No tool calls - this is the final answer
+		if len(msg.ToolCalls) == 0 {
+			if err := o.saveSession(sessionID, messages); err != nil {
+				log.Printf("[OpenAI] Failed to save session %s: %v", sessionID, err)
+			}
+			res.Response = msg.Content
+			if res.Response == "" {
+				res.Response = "✅ Done (no output)"
+			}
+			emit(Event{Type: EventResult, SessionID: sessionID, Text: msg.Content})
+			return res
+		}
+
+		for _, call := range msg.ToolCalls {
+			messages = append(messages, openAIMessage{
+				Role:       "tool",
+				ToolCallID: call.ID,
+				Content:    o.runTool(tools, call, emit),
+			})
+		}
+	}
+
+	// Keep the conversation so far, so the user can ask the model to continue
+	if err := o.saveSession(sessionID, messages); err != nil {
+		log.Printf("[OpenAI] Failed to save session %s: %v", sessionID, err)
+	}
+	return res.fail(ErrorUnknown, fmt.Sprintf("stopped after %d tool rounds without a final answer", maxToolRounds))
+}
+
+// runTool runs a tool call and returns its result for the model. Malformed arguments
+// are reported back to the model as a tool error instead of running the tool.
+func (o *OpenAI) runTool(tools *vaultTools, call openAIToolCall, emit EventHandler) string {
+	arguments := call.Function.Arguments
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}" // Some servers send no arguments for tools without required ones
+	}
+	var input map[string]any
+	if err := json.Unmarshal([]byte(arguments), &input); err != nil {
+		log.Printf("[OpenAI] Invalid arguments for %s: %v", call.Function.Name, err)
+		return fmt.Sprintf("error: invalid arguments for %s: %v", call.Function.Name, err)
+	}
+
+	event := toolEvent("", call.Function.Name, input)
+	if action, ok := vaultToolActions[call.Function.Name]; ok {
+		event.Action = action
+	}
+	emit(event)
+	return tools.call(call.Function.Name, arguments)
+}
+
+// complete sends one chat-completions request
+func (o *OpenAI) complete(ctx context.Context, model string, messages []openAIMessage, tools []toolSpec) (*openAIResponse, error) {
+	body, err := json.Marshal(openAIRequest{Model: model, Messages: messages, Tools: tools})
+	if err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimSuffix(o.config.BaseURL, "/") + "/chat/completions"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.config.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.config.APIKey)
+	}
+
+	httpResp, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if httpResp.StatusCode/100 != 2 {
+		return nil, &openAIStatusError{Status: httpResp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+
+	var resp openAIResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("%w: %v", errParse, err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%w: response has no choices", errParse)
+	}
+	return &resp, nil
+}
+
+// errParse marks responses that could not be understood
+var errParse = errors.New("invalid API response")
+
+// classifyHTTPError determines the error kind of a failed API request
+func classifyHTTPError(err error) ErrorKind {
+	var statusErr *openAIStatusError
+	switch {
+	case errors.As(err, &statusErr):
+		switch {
+		case statusErr.Status == http.StatusUnauthorized || statusErr.Status == http.StatusForbidden:
+			return ErrorAuth
+		case statusErr.Status == http.StatusTooManyRequests:
+			return ErrorRateLimit
+		case statusErr.Status >= 500:
+			return ErrorUnavailable
+		}
+		return ErrorUnknown
+	case errors.Is(err, errParse):
+		return ErrorParse
+	}
+	// Transport errors: connection refused, DNS failure, etc.
+	return ErrorUnavailable
+}
+
+// loadSession returns the stored conversation for sessionID, or starts a new session
+func (o *OpenAI) loadSession(sessionID string) ([]openAIMessage, string, error) {
+	if sessionID == "" || !sessionIDPattern.MatchString(sessionID) {
+		id, err := newSessionID()
+		if err != nil {
+			return nil, "", err
+		}
+		return []openAIMessage{{Role: "system", Content: openAISystemPrompt}}, id, nil
+	}
+
+	var messages []openAIMessage
+	if err := jsonfile.Load(o.sessionPath(sessionID), &messages); err != nil {
+		return nil, "", err
+	}
+	if len(messages) == 0 {
+		// Unknown session (e.g. state was cleared) - start over under the same ID
+		messages = []openAIMessage{{Role: "system", Content: openAISystemPrompt}}
+	}
+	return messages, sessionID, nil
+}
+
+// newSessionID returns a random session ID
+func newSessionID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// saveSession stores the conversation for sessionID
+func (o *OpenAI) saveSession(sessionID string, messages []openAIMessage) error {
+	return jsonfile.Save(o.sessionPath(sessionID), messages)
+}
+
+// sessionPath returns the conversation file for sessionID
+func (o *OpenAI) sessionPath(sessionID string) string {
+	dir := o.config.StateDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "obsidian-pa")
+	}
+	return filepath.Join(dir, "openai-sessions", sessionID+".json")
+}
+
+// EnforcesAppend reports that append mode is enforced: only append_file can change notes
+func (o *OpenAI) EnforcesAppend() bool {
+	return true
+}
+
+// GetStartPrompt returns the prompt used for the /start command
+func (o *OpenAI) GetStartPrompt() string {
+	return StartPrompt
+}
@@ -0,0 +1,257 @@
+//go:build unix
+
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeCompletions stands in for a chat-completions server, answering each request
+// with the next scripted message and recording what it was sent
+type fakeCompletions struct {
+	mu       sync.Mutex
+	replies  []openAIMessage
+	requests []openAIRequest
+}
+
+func (f *fakeCompletions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var req openAIRequest
+	if r.URL.Path != "/v1/chat/completions" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	f.requests = append(f.requests, req)
+	if len(f.replies) == 0 {
+		http.Error(w, "no more replies", http.StatusInternalServerError)
+		return
+	}
+	reply := f.replies[0]
+	f.replies = f.replies[1:]
+
+	resp := map[string]any{
+		"model":   "fake-model",
+		"choices": []map[string]any{{"message": reply}},
+		"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 5},
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// newFakeOpenAI returns an executor talking to a fake server with the scripted replies
+func newFakeOpenAI(t *testing.T, vault string, replies ...openAIMessage) (*OpenAI, *fakeCompletions) {
+	t.Helper()
+	fake := &fakeCompletions{replies: replies}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return NewOpenAI(&Config{
+		VaultPath: vault,
+		Model:     "local",
+		BaseURL:   server.URL + "/v1",
+		StateDir:  t.TempDir(),
+	}), fake
+}
+
+// callTool is a model message asking for one tool call
+func callTool(id, name, arguments string) openAIMessage {
+	call := openAIToolCall{ID: id, Type: "function"}
+	call.Function.Name, call.Function.Arguments = name, arguments
+	return openAIMessage{Role: "assistant", ToolCalls: []openAIToolCall{call}}
+}
+
+// answer is a final model message
+func answer(text string) openAIMessage {
+	return openAIMessage{Role: "assistant", Content: text}
+}
+
+// lastTool returns the content of the last tool message in a request
+func lastTool(req openAIRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "tool" {
+			return req.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+func TestOpenAIToolRoundTrip(t *testing.T) {
+	vault, _ := testVault(t)
+	o, fake := newFakeOpenAI(t, vault,
+		callTool("call-1", "append_file", `{"path": "Inbox.md", "content": "- milk\n"}`),
+		callTool("call-2", "read_file", `{"path": "Inbox.md"}`),
+		answer("Added milk to your inbox."),
+	)
+
+	var events []Event
+	res := o.Execute(context.Background(), Request{Prompt: "add milk", Mode: ModeAppend}, func(ev Event) {
+		events = append(events, ev)
+	})
+	if res.Failed() {
+		t.Fatalf("run failed: %s", res.Error)
+	}
+	if res.Response != "Added milk to your inbox." || res.Model != "fake-model" {
+		t.Errorf("got response %q from %q", res.Response, res.Model)
+	}
+	if res.Usage.InputTokens != 30 || res.Usage.OutputTokens != 15 {
+		t.Errorf("usage %+v, want three requests counted", res.Usage)
+	}
+	if data, _ := os.ReadFile(filepath.Join(vault, "Inbox.md")); string(data) != "- milk\n" {
+		t.Errorf("Inbox.md is %q", data)
+	}
+
+	// The append mode offers no write_file, and every tool result went back to the model
+	if len(fake.requests) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(fake.requests))
+	}
+	for _, spec := range fake.requests[0].Tools {
+		if spec.Function.Name == "write_file" {
+			t.Error("offered write_file in append mode")
+		}
+	}
+	if got := lastTool(fake.requests[1]); !strings.HasPrefix(got, "ok: wrote") {
+		t.Errorf("append result %q", got)
+	}
+	if got := lastTool(fake.requests[2]); got != "- milk\n" {
+		t.Errorf("read result %q", got)
+	}
+
+	var actions []ToolAction
+	for _, ev := range events {
+		if ev.Type == EventToolUse {
+			actions = append(actions, ev.Action)
+		}
+	}
+	if len(actions) != 2 || actions[0] != ActionEdit || actions[1] != ActionRead {
+		t.Errorf("tool events %v, want edit then read", actions)
+	}
+}
+
+func TestOpenAIRejectsToolCallsOutsideMode(t *testing.T) {
+	vault, _ := testVault(t)
+	o, fake := newFakeOpenAI(t, vault,
+		callTool("call-1", "write_file", `{"path": "Notes/a.md", "content": "gone"}`),
+		callTool("call-2", "read_file", `{"path": "../outside/secret.md"}`),
+		answer("I can't do that."),
+	)
+
+	res := o.Execute(context.Background(), Request{Prompt: "overwrite a"}, nil)
+	if res.Failed() {
+		t.Fatalf("run failed: %s", res.Error)
+	}
+	if got := lastTool(fake.requests[1]); !strings.Contains(got, "not permitted") {
+		t.Errorf("write in read-only mode returned %q", got)
+	}
+	if got := lastTool(fake.requests[2]); !strings.Contains(got, "outside the vault") {
+		t.Errorf("escaping read returned %q", got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(vault, "Notes", "a.md")); string(data) != "alpha\n" {
+		t.Errorf("note was changed to %q", data)
+	}
+}
+
+func TestOpenAIReportsMalformedToolArguments(t *testing.T) {
+	vault, _ := testVault(t)
+	o, fake := newFakeOpenAI(t, vault,
+		callTool("call-1", "write_file", `{"path": "Notes/a.md", "content": `),
+		answer("Sorry, let me try again later."),
+	)
+
+	var tools int
+	res := o.Execute(context.Background(), Request{Prompt: "rewrite a", Mode: ModeFull}, func(ev Event) {
+		if ev.Type == EventToolUse {
+			tools++
+		}
+	})
+	if res.Failed() {
+		t.Fatalf("run failed: %s", res.Error)
+	}
+	got := fake.requests[1].Messages[len(fake.requests[1].Messages)-1]
+	if got.Role != "tool" || got.ToolCallID != "call-1" || !strings.HasPrefix(got.Content, "error: invalid arguments") {
+		t.Errorf("model was sent %+v, want a tool error for call-1", got)
+	}
+	if tools != 0 {
+		t.Errorf("reported %d tool runs for a malformed call", tools)
+	}
+	if data, _ := os.ReadFile(filepath.Join(vault, "Notes", "a.md")); string(data) != "alpha\n" {
+		t.Errorf("note was changed to %q", data)
+	}
+}
+
+func TestOpenAIResumesSessions(t *testing.T) {
+	vault, _ := testVault(t)
+	o, fake := newFakeOpenAI(t, vault,
+		answer("Hello!"),
+		answer("You said hi."),
+		answer("Forked."),
+	)
+
+	first := o.Execute(context.Background(), Request{Prompt: "hi"}, nil)
+	if first.Failed() || !sessionIDPattern.MatchString(first.SessionID) {
+		t.Fatalf("first run: %+v", first)
+	}
+
+	// Resuming sends the stored conversation before the new prompt
+	second := o.Execute(context.Background(), Request{Prompt: "what did I say?", SessionID: first.SessionID}, nil)
+	if second.SessionID != first.SessionID {
+		t.Errorf("resumed as %q, want %q", second.SessionID, first.SessionID)
+	}
+	var history []string
+	for _, msg := range fake.requests[1].Messages {
+		history = append(history, msg.Role+": "+msg.Content)
+	}
+	want := []string{"user: hi", "assistant: Hello!", "user: what did I say?"}
+	if len(history) != 4 || strings.Join(history[1:], "\n") != strings.Join(want, "\n") {
+		t.Errorf("resumed with %q, want the system prompt then %q", history, want)
+	}
+
+	// Forking continues a copy under a new ID, leaving the original unchanged
+	fork := o.Execute(context.Background(), Request{Prompt: "fork", SessionID: first.SessionID, Fork: true}, nil)
+	if fork.SessionID == first.SessionID || !sessionIDPattern.MatchString(fork.SessionID) {
+		t.Errorf("fork got session %q", fork.SessionID)
+	}
+	if got := len(fake.requests[2].Messages); got != 6 {
+		t.Errorf("fork sent %d messages, want the whole history and the prompt", got)
+	}
+	original, _, err := o.loadSession(first.SessionID)
+	if err != nil || len(original) != 5 {
+		t.Errorf("original session has %d messages (%v), want 5", len(original), err)
+	}
+
+	// IDs that aren't ours start a new session instead of touching other files
+	res := o.Execute(context.Background(), Request{Prompt: "hi", SessionID: "../../etc/passwd"}, nil)
+	if res.SessionID == "../../etc/passwd" {
+		t.Error("used an unvalidated session ID")
+	}
+}
+
+func TestOnlyOpenAIEnforcesAppend(t *testing.T) {
+	config := &Config{}
+	if !EnforcesAppend(NewOpenAI(config)) {
+		t.Error("OpenAI doesn't enforce append mode")
+	}
+	if EnforcesAppend(NewClaude(config)) || EnforcesAppend(NewGemini(config)) {
+		t.Error("a CLI claims to enforce append mode")
+	}
+	if NewChain(NewOpenAI(config), NewClaude(config)).EnforcesAppend() {
+		t.Error("a chain with a Claude fallback claims to enforce append mode")
+	}
+}
+
+func TestOnlyClaudeReportsCost(t *testing.T) {
+	config := &Config{}
+	if !ReportsCost(NewClaude(config)) {
+		t.Error("Claude doesn't report cost")
+	}
+	if ReportsCost(NewGemini(config)) || ReportsCost(NewOpenAI(config)) {
+		t.Error("an executor without cost claims to report it")
+	}
+}
@@ -0,0 +1,114 @@
+// Package executor provides permission modes that restrict what a run may change.
+package executor
+
+import "strings"
+
+// PermissionMode restricts which tools a run may use
+type PermissionMode string
+
+const (
+	ModeReadOnly PermissionMode = "read"   // Search and read tools only
+	ModeAppend   PermissionMode = "append" // Read tools plus adding content to notes (see EnforcesAppend)
+	ModeFull     PermissionMode = "full"   // All tools, including shell and overwriting notes
+)
+
+// ParsePermissionMode parses a mode name, accepting a few common aliases
+func ParsePermissionMode(name string) (PermissionMode, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "read", "readonly", "read-only", "ro":
+		return ModeReadOnly, true
+	case "append", "append-only":
+		return ModeAppend, true
+	case "full", "write", "rw":
+		return ModeFull, true
+	}
+	return "", false
+}
+
+// orReadOnly returns the mode, treating an unset mode as read-only
+func (m PermissionMode) orReadOnly() PermissionMode {
+	if m == "" {
+		return ModeReadOnly
+	}
+	return m
+}
+
+// modeRank orders the modes from least to most permissive
+var modeRank = map[PermissionMode]int{ModeReadOnly: 0, ModeAppend: 1, ModeFull: 2}
+
+// AtMost returns the mode, lowered to limit if it permits more than limit
+func (m PermissionMode) AtMost(limit PermissionMode) PermissionMode {
+	if modeRank[m.orReadOnly()] > modeRank[limit.orReadOnly()] {
+		return limit.orReadOnly()
+	}
+	return m
+}
+
+// AppendEnforcer is implemented by executors whose own tools enforce append mode
+type AppendEnforcer interface {
+	EnforcesAppend() bool
+}
+
+// EnforcesAppend reports whether an executor enforces append mode. The CLIs have no
+// append-only tool: in append mode they may create and edit notes and are only told
+// not to rewrite them.
+func EnforcesAppend(e Executor) bool {
+	enforcer, ok := e.(AppendEnforcer)
+	return ok && enforcer.EnforcesAppend()
+}
+
+// appendOnlyNotice is added to prompts in append mode for CLIs without a dedicated
+// append tool, where only shell commands can be blocked
+const appendOnlyNotice = `
+
+IMPORTANT: You are in append-only mode. You may create new notes and add content to the end of existing notes, but you must never delete, rewrite or reorder existing content.`
+
+// Claude CLI tool names per mode
+var (
+	claudeReadTools   = "Read,Glob,Grep,LS,NotebookRead,WebFetch,WebSearch,TodoWrite"
+	claudeAppendTools = claudeReadTools + ",Write,Edit,MultiEdit"
+)
+
+// claudePermissionArgs returns the Claude CLI flags for a mode
+func claudePermissionArgs(mode PermissionMode) []string {
+	switch mode.orReadOnly() {
+	case ModeFull:
+		return []string{"--dangerously-skip-permissions"}
+	case ModeAppend:
+		// No append-only tool: edits are allowed (shell commands aren't), and the append rule
+		// is only an instruction - see EnforcesAppend
+		return []string{"--allowedTools", claudeAppendTools, "--disallowedTools", "Bash,NotebookEdit"}
+	}
+	return []string{"--allowedTools", claudeReadTools, "--disallowedTools", "Bash,Write,Edit,MultiEdit,NotebookEdit"}
+}
+
+// Gemini CLI tool names for read-only mode
+const geminiReadTools = "read_file,read_many_files,glob,search_file_content,list_directory,web_fetch,google_web_search"
+
+// geminiPermissionArgs returns the Gemini CLI flags for a mode. In non-interactive
+// mode Gemini excludes tools that would need confirmation.
+func geminiPermissionArgs(mode PermissionMode) []string {
+	switch mode.orReadOnly() {
+	case ModeFull:
+		return []string{"--yolo"} // Auto-accept all permissions
+	case ModeAppend:
+		// Auto-approve file edits, but not shell commands; the append rule is only an instruction
+		return []string{"--approval-mode", "auto_edit"}
+	}
+	return []string{"--allowed-tools", geminiReadTools}
+}
+
+// vaultToolsByMode lists the vault tools offered in each mode
+var vaultToolsByMode = map[PermissionMode][]string{
+	ModeReadOnly: {"read_file", "list_directory", "search_files"},
+	ModeAppend:   {"read_file", "list_directory", "search_files", "append_file"},
+	ModeFull:     {"read_file", "list_directory", "search_files", "append_file", "write_file"},
+}
+
+// withModeNotice adds the append-only notice to a prompt when needed
+func withModeNotice(prompt string, mode PermissionMode) string {
+	if mode == ModeAppend {
+		return prompt + appendOnlyNotice
+	}
+	return prompt
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+// Package executor provides process management for AI CLI runs.
+package executor
+
+import "os/exec"
+
+// setProcessGroup is a no-op on platforms without process groups;
+// context cancellation only kills the CLI process itself
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// limitCommand leaves the command unchanged; resource limits are only supported on unix
+func limitCommand(limits Limits, name string, args []string) (string, []string) {
+	return name, args
+}
@@ -0,0 +1,50 @@
+//go:build unix
+
+// Package executor provides process management for AI CLI runs.
+package executor
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup starts cmd in its own process group and makes context
+// cancellation kill the whole group, so tools spawned by the CLI die with it
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		// A negative PID signals every process in the group
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// limitCommand wraps a command in a shell that sets the resource limits and then
+// execs it, so the limits apply to the CLI and everything it spawns
+func limitCommand(limits Limits, name string, args []string) (string, []string) {
+	var ulimits []string
+	if limits.CPUTime > 0 {
+		seconds := int64((limits.CPUTime + time.Second - 1) / time.Second) // Round up
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -t %d", seconds))
+	}
+	if limits.MemoryMB > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -d %d", limits.MemoryMB*1024))
+	}
+	if limits.OpenFiles > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -n %d", limits.OpenFiles))
+	}
+	if len(ulimits) == 0 {
+		return name, args
+	}
+
+	// Resolve the binary here so a missing CLI is still reported as exec.ErrNotFound
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return name, args
+	}
+
+	script := strings.Join(ulimits, " && ") + ` && exec "$0" "$@"`
+	return "/bin/sh", append([]string{"-c", script, path}, args...)
+}
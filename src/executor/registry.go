@@ -0,0 +1,115 @@
+// Package executor provides a registry of configured executors and their models.
+package executor
+
+import "strings"
+
+// Model is a model an executor can run, with a short alias for chat commands
+type Model struct {
+	Alias string // e.g. "sonnet"
+	ID    string // e.g. "claude-sonnet-4-5"
+}
+
+// registration is an executor with its default and selectable models
+type registration struct {
+	executor     Executor
+	defaultModel string
+	models       []Model
+}
+
+// Registry holds the configured executors in fallback order, and the models each can run
+type Registry struct {
+	entries []registration
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds an executor after those already registered
+func (r *Registry) Register(e Executor, defaultModel string, models []Model) {
+	r.entries = append(r.entries, registration{executor: e, defaultModel: defaultModel, models: models})
+}
+
+// Executors returns the registered executors in fallback order
+func (r *Registry) Executors() []Executor {
+	executors := make([]Executor, len(r.entries))
+	for i, entry := range r.entries {
+		executors[i] = entry.executor
+	}
+	return executors
+}
+
+// Lookup finds an executor by name (case-insensitive)
+func (r *Registry) Lookup(name string) (Executor, bool) {
+	entry, ok := r.entry(name)
+	if !ok {
+		return nil, false
+	}
+	return entry.executor, true
+}
+
+// DefaultModel returns the configured model of an executor
+func (r *Registry) DefaultModel(name string) string {
+	entry, _ := r.entry(name)
+	return entry.defaultModel
+}
+
+// Models returns the selectable models of an executor
+func (r *Registry) Models(name string) []Model {
+	entry, _ := r.entry(name)
+	return entry.models
+}
+
+// FindModel resolves a model alias or ID across all executors.
+// Returns the owning executor's name and the model ID.
+func (r *Registry) FindModel(name string) (executorName string, modelID string, ok bool) {
+	for _, entry := range r.entries {
+		for _, m := range entry.models {
+			if strings.EqualFold(m.Alias, name) || strings.EqualFold(m.ID, name) {
+				return entry.executor.Name(), m.ID, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// Chain returns a fallback chain starting with the named executor, followed by
+// the others in registration order. An unknown name keeps the registration order.
+func (r *Registry) Chain(primary string) *Chain {
+	executors := r.Executors()
+	for i, e := range executors {
+		if strings.EqualFold(e.Name(), primary) {
+			executors = append([]Executor{e}, append(executors[:i:i], executors[i+1:]...)...)
+			break
+		}
+	}
+	return NewChain(executors...)
+}
+
+// entry finds a registration by executor name (case-insensitive)
+func (r *Registry) entry(name string) (registration, bool) {
+	for _, entry := range r.entries {
+		if strings.EqualFold(entry.executor.Name(), name) {
+			return entry, true
+		}
+	}
+	return registration{}, false
+}
+
+// ParseModels parses a comma-separated list of "alias=model-id" or "model-id" entries
+func ParseModels(list string) []Model {
+	var models []Model
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		alias, id, found := strings.Cut(item, "=")
+		if !found {
+			id = alias
+		}
+		models = append(models, Model{Alias: strings.TrimSpace(alias), ID: strings.TrimSpace(id)})
+	}
+	return models
+}
@@ -0,0 +1,144 @@
+// Package executor provides the structured outcome of an AI CLI run.
+package executor
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrorKind classifies why a run failed
+type ErrorKind string
+
+const (
+	ErrorNone        ErrorKind = ""            // Run succeeded
+	ErrorAuth        ErrorKind = "auth"        // Missing or invalid credentials
+	ErrorRateLimit   ErrorKind = "rate_limit"  // Rate limited or quota exhausted
+	ErrorUnavailable ErrorKind = "unavailable" // Service overloaded, down or unreachable
+	ErrorTimeout     ErrorKind = "timeout"     // Configured timeout elapsed
+	ErrorCancelled   ErrorKind = "cancelled"   // Aborted by the user (/cancel)
+	ErrorCLIMissing  ErrorKind = "cli_missing" // CLI binary not found on PATH
+	ErrorParse       ErrorKind = "parse"       // CLI output could not be understood
+	ErrorUnknown     ErrorKind = "unknown"     // Any other failure
+)
+
+// Transient reports whether the failure is likely specific to the backend right now,
+// so retrying on another executor may succeed
+func (k ErrorKind) Transient() bool {
+	return k == ErrorRateLimit || k == ErrorUnavailable
+}
+
+// Usage holds token counts reported by the CLI
+type Usage struct {
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+}
+
+// ToolCall records a single tool invocation during a run
+type ToolCall struct {
+	Tool   string
+	Action ToolAction
+	Path   string
+}
+
+// Result is the outcome of a single run
+type Result struct {
+	Response     string        // Final response text (empty on failure)
+	Executor     string        // Name of the executor that produced this result
+	Fallbacks    []string      // Executors tried first that failed transiently, in order
+	SessionID    string        // Session ID for continuity (may be set on failure)
+	ErrorKind    ErrorKind     // Why the run failed (ErrorNone on success)
+	Error        string        // Error detail from the CLI
+	Model        string        // Model that answered, as reported by the CLI
+	Duration     time.Duration // Wall time of the run
+	Usage        Usage         // Token usage, if reported
+	CostUSD      float64       // Cost in USD, if reported
+	ToolCalls    []ToolCall    // Tools invoked, in order
+	FilesTouched []string      // Vault files written or edited, deduplicated
+}
+
+// Failed reports whether the run failed
+func (r *Result) Failed() bool {
+	return r.ErrorKind != ErrorNone
+}
+
+// fail marks the result as failed with the given kind and detail
+func (r *Result) fail(kind ErrorKind, detail string) *Result {
+	r.ErrorKind = kind
+	r.Error = detail
+	r.Response = ""
+	return r
+}
+
+// track wraps onEvent so tool calls and the model are also recorded in the result
+func (r *Result) track(onEvent EventHandler) EventHandler {
+	return func(event Event) {
+		switch event.Type {
+		case EventInit:
+			if event.Model != "" {
+				r.Model = event.Model
+			}
+		case EventToolUse:
+			r.ToolCalls = append(r.ToolCalls, ToolCall{Tool: event.Tool, Action: event.Action, Path: event.Path})
+			if (event.Action == ActionWrite || event.Action == ActionEdit) && event.Path != "" && !contains(r.FilesTouched, event.Path) {
+				r.FilesTouched = append(r.FilesTouched, event.Path)
+			}
+		}
+		onEvent.emit(event)
+	}
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// abortKind classifies a run stopped by its context.
+// Returns false if the context is still live.
+func abortKind(ctx context.Context) (ErrorKind, bool) {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return ErrorTimeout, true
+	case errors.Is(ctx.Err(), context.Canceled):
+		return ErrorCancelled, true
+	}
+	return ErrorNone, false
+}
+
+// classifyError determines the error kind from a process error and CLI output
+func classifyError(err error, output string) ErrorKind {
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrorCLIMissing
+	}
+
+	lower := strings.ToLower(output)
+	switch {
+	case containsAny(lower, "rate limit", "rate_limit", "429", "too many requests", "quota", "resource_exhausted"):
+		return ErrorRateLimit
+	case containsAny(lower, "overloaded", "api error: 5", "service unavailable", "internal server error", "bad gateway",
+		"econnreset", "econnrefused", "etimedout", "enotfound", "fetch failed"):
+		return ErrorUnavailable
+	case containsAny(lower, "401", "authentication", "invalid api key", "invalid x-api-key", "api key not valid", "unauthorized", "not logged in", "/login"):
+		return ErrorAuth
+	}
+	return ErrorUnknown
+}
+
+// containsAny reports whether s contains any of the substrings
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
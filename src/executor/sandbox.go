@@ -0,0 +1,100 @@
+// Package executor provides the environment and resource limits of AI CLI processes.
+package executor
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultEnv are the environment variables passed through to every executor process.
+// Anything else (bot tokens in particular) stays in the bot's own environment.
+var DefaultEnv = []string{
+	"PATH", "USER", "LOGNAME", "LANG", "LC_ALL", "TZ", "TERM", "TMPDIR",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "NODE_EXTRA_CA_CERTS",
+}
+
+// platformSecrets are never passed in executor processes' environment, even when allowlisted,
+// so a CLI doesn't hand them to every command it runs. The CLIs run as the bot's user, so
+// this isn't a hard boundary: a full-mode shell can still read /proc/<bot pid>/environ.
+var platformSecrets = map[string]bool{
+	"TELEGRAM_TOKEN":  true,
+	"SLACK_BOT_TOKEN": true,
+	"SLACK_APP_TOKEN": true,
+	"DISCORD_TOKEN":   true,
+}
+
+// Limits are optional resource limits applied to executor processes (0 = unlimited)
+type Limits struct {
+	CPUTime   time.Duration // CPU time of the CLI process
+	MemoryMB  int           // Data segment (heap) size of the CLI process, in MiB
+	OpenFiles int           // Open file descriptors
+}
+
+// childEnv builds the environment of an executor process: the allowlisted variables
+// from the bot's environment, a dedicated HOME, and extra "KEY=value" entries
+func childEnv(config *Config, extra ...string) []string {
+	var env []string
+	for _, key := range config.Env {
+		if platformSecrets[key] || key == "HOME" {
+			continue
+		}
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+
+	home := config.HomeDir
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	if home != "" {
+		env = append(env,
+			"HOME="+home,
+			"XDG_CONFIG_HOME="+filepath.Join(home, ".config"),
+			"XDG_CACHE_HOME="+filepath.Join(home, ".cache"),
+			"XDG_DATA_HOME="+filepath.Join(home, ".local", "share"),
+		)
+	}
+
+	for _, entry := range extra {
+		key, _, _ := strings.Cut(entry, "=")
+		if platformSecrets[key] {
+			continue
+		}
+		env = append(env, entry)
+	}
+	return env
+}
+
+// prepareHome creates the executor's HOME and links the configured entries of the
+// bot's own HOME into it (e.g. an existing OAuth login in ~/.gemini)
+func prepareHome(config *Config) {
+	if config.HomeDir == "" {
+		return
+	}
+	if err := os.MkdirAll(config.HomeDir, 0o700); err != nil {
+		log.Printf("Failed to create executor home %s: %v", config.HomeDir, err)
+		return
+	}
+
+	botHome, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	for _, name := range config.HomeLinks {
+		source := filepath.Join(botHome, name)
+		target := filepath.Join(config.HomeDir, name)
+		if _, err := os.Stat(source); err != nil {
+			continue
+		}
+		if _, err := os.Lstat(target); err == nil {
+			continue // Already linked, or the executor has its own copy
+		}
+		if err := os.Symlink(source, target); err != nil {
+			log.Printf("Failed to link %s into executor home: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+// Package executor provides a declarative executor for additional agent CLIs.
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gpng/obsidian-pa/src/jsonfile"
+)
+
+// Output formats supported by template executors
+const (
+	OutputJSON   = "json"   // A single JSON document
+	OutputNDJSON = "ndjson" // Newline-delimited JSON events
+	OutputText   = "text"   // Plain text response
+)
+
+// eventError is an NDJSON rule type that reports a failure instead of an event
+const eventError EventType = "error"
+
+// TemplateSpec declares how to run an agent CLI and parse its output.
+// Argument placeholders: {prompt}, {vault}, {model} and {session}. An argument that
+// is exactly {model_args}, {resume_args} or {permission_args} is replaced by those
+// argument lists (or removed when not applicable); lists without a placeholder are appended.
+type TemplateSpec struct {
+	Name       string   `json:"name"`        // Executor name, also used in AI_EXECUTOR
+	Binary     string   `json:"binary"`      // CLI binary on PATH
+	Args       []string `json:"args"`        // Arguments for every run
+	ModelArgs  []string `json:"model_args"`  // Used when a model is set, e.g. ["--model", "{model}"]
+	ResumeArgs []string `json:"resume_args"` // Used when resuming, e.g. ["--resume", "{session}"]
+	ForkArgs   []string `json:"fork_args"`   // Added to resume_args to branch a session, e.g. ["--fork"]
+
+	// Arguments per permission mode ("read", "append", "full"). A CLI without an
+	// entry for the requested mode is refused, so it can't run with more access.
+	PermissionArgs map[PermissionMode][]string `json:"permission_args"`
+
+	Model  string            `json:"model"`   // Default model (may be empty)
+	Models string            `json:"models"`  // Models selectable with /model, as "alias=model,..."
+	Env    []string          `json:"env"`     // Environment variables passed through
+	SetEnv map[string]string `json:"set_env"` // Environment variables set to fixed values
+	Output OutputSpec        `json:"output"`
+
+	// Entries of the bot's HOME linked into the CLI's own HOME, e.g. [".codex"] for its login
+	HomeLinks []string `json:"home_links"`
+}
+
+// OutputSpec declares how to parse a template CLI's output
+type OutputSpec struct {
+	Format string `json:"format"` // json, ndjson or text
+
+	// For json: dotted field paths into the document (e.g. "result" or "message.content.0.text")
+	Result    string `json:"result"`
+	SessionID string `json:"session_id"`
+	Error     string `json:"error"` // Non-empty/true value marks the run as failed
+
+	// For ndjson: rules mapping events to progress events, first match wins
+	Events []EventRule `json:"events"`
+}
+
+// EventRule maps matching NDJSON events to a progress event.
+// Field values are dotted paths into the event.
+type EventRule struct {
+	Match     map[string]string `json:"match"` // Path → required value, e.g. {"type": "item.completed"}
+	Type      EventType         `json:"type"`  // init, text, tool_use, result or error
+	SessionID string            `json:"session_id"`
+	Model     string            `json:"model"`
+	Text      string            `json:"text"`
+	Tool      string            `json:"tool"`
+	Path      string            `json:"path"`
+	Action    ToolAction        `json:"action"` // Fixed action for tool_use (default: from the tool name)
+}
+
+// LoadTemplates reads template executor specs from a JSON file.
+// A missing file yields no templates.
+func LoadTemplates(path string) ([]TemplateSpec, error) {
+	var specs []TemplateSpec
+	if err := jsonfile.Load(path, &specs); err != nil {
+		return nil, fmt.Errorf("load executor templates: %w", err)
+	}
+	for _, spec := range specs {
+		if spec.Name == "" || spec.Binary == "" {
+			return nil, fmt.Errorf("executor template needs a name and binary: %+v", spec)
+		}
+		switch spec.Output.Format {
+		case OutputJSON, OutputNDJSON, OutputText:
+		default:
+			return nil, fmt.Errorf("executor template %s: unknown output format %q", spec.Name, spec.Output.Format)
+		}
+	}
+	return specs, nil
+}
+
+// Template implements the Executor interface for a CLI declared by a TemplateSpec
+type Template struct {
+	config *Config
+	spec   TemplateSpec
+}
+
+// NewTemplate creates a new template executor
+func NewTemplate(config *Config, spec TemplateSpec) *Template {
+	return &Template{config: config, spec: spec}
+}
+
+// Name returns the executor name for logging
+func (t *Template) Name() string {
+	return t.spec.Name
+}
+
+// Execute runs the declared CLI with the given prompt and returns the result
+func (t *Template) Execute(ctx context.Context, req Request, onEvent EventHandler) *Result {
+	model := req.model(t.config)
+	res := &Result{Model: model}
+
+	mode := req.Mode.orReadOnly()
+	permissionArgs, ok := t.spec.PermissionArgs[mode]
+	if !ok {
+		return res.fail(ErrorUnknown, fmt.Sprintf("%s has no arguments for %s mode in its template", t.spec.Name, mode))
+	}
+
+	vars := map[string]string{
+		"{prompt}":  withModeNotice(req.Prompt, mode),
+		"{vault}":   t.config.VaultPath,
+		"{model}":   model,
+		"{session}": req.SessionID,
+	}
+
+	var modelArgs, resumeArgs []string
+	if model != "" {
+		modelArgs = expandArgs(t.spec.ModelArgs, vars)
+	}
+	switch {
+	case req.SessionID == "":
+	case !req.Fork:
+		resumeArgs = expandArgs(t.spec.ResumeArgs, vars)
+	case len(t.spec.ForkArgs) > 0:
+		resumeArgs = append(expandArgs(t.spec.ResumeArgs, vars), expandArgs(t.spec.ForkArgs, vars)...)
+	default:
+		// The CLI can't branch a session - start a new one rather than continue the original
+	}
+	args := spliceArgs(expandArgs(t.spec.Args, vars), "{model_args}", modelArgs)
+	args = spliceArgs(args, "{resume_args}", resumeArgs)
+	args = spliceArgs(args, "{permission_args}", expandArgs(permissionArgs, vars))
+
+	cmd, runCtx, cancel := newCommand(ctx, t.config, t.spec.Binary, args...)
+	defer cancel()
+	cmd.Env = childEnv(t.config, t.env()...)
+
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+	emit := res.track(onEvent)
+
+	var rawOutput, errMsg string
+	var err error
+	var parsed bool
+
+	if t.spec.Output.Format == OutputNDJSON {
+		var text strings.Builder
+		rawOutput, err = streamCommand(cmd, func(line []byte) {
+			var event any
+			if err := json.Unmarshal(line, &event); err != nil {
+				log.Printf("[%s] Failed to parse JSON event: %v", t.spec.Name, err)
+				return
+			}
+			parsed = true
+			t.handleEvent(event, res, emit, &text, &errMsg)
+		})
+		if res.Response == "" {
+			res.Response = text.String()
+		}
+	} else {
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err = cmd.Run()
+		rawOutput = strings.TrimSpace(stderr.String())
+
+		if t.spec.Output.Format == OutputText {
+			res.Response = strings.TrimSpace(stdout.String())
+			parsed = true
+		} else {
+			var doc any
+			if jsonErr := json.Unmarshal(stdout.Bytes(), &doc); jsonErr == nil {
+				parsed = true
+				res.Response = lookupString(doc, t.spec.Output.Result)
+				res.SessionID = lookupString(doc, t.spec.Output.SessionID)
+				errMsg = lookupString(doc, t.spec.Output.Error)
+				if errMsg == "true" {
+					errMsg = res.Response
+				}
+			} else if rawOutput == "" {
+				rawOutput = strings.TrimSpace(stdout.String())
+			}
+		}
+	}
+
+	// Report cancellation and timeouts rather than the killed process' output
+	if kind, aborted := abortKind(runCtx); aborted {
+		return res.fail(kind, runCtx.Err().Error())
+	}
+
+	if err != nil || (errMsg != "" && errMsg != "false") {
+		if errMsg != "" && errMsg != "false" {
+			rawOutput = errMsg
+		}
+		if rawOutput == "" {
+			rawOutput = err.Error()
+		}
+		log.Printf("[%s] Run failed: %s", t.spec.Name, rawOutput)
+		return res.fail(classifyError(err, rawOutput), rawOutput)
+	}
+
+	if !parsed {
+		log.Printf("[%s] Output did not match format %s", t.spec.Name, t.spec.Output.Format)
+		return res.fail(ErrorParse, rawOutput)
+	}
+
+	if res.Response == "" {
+		res.Response = "✅ Done (no output)"
+	}
+	return res
+}
+
+// handleEvent applies the first matching NDJSON rule to an event
+func (t *Template) handleEvent(event any, res *Result, emit EventHandler, text *strings.Builder, errMsg *string) {
+	for _, rule := range t.spec.Output.Events {
+		if !rule.matches(event) {
+			continue
+		}
+
+		if id := lookupString(event, rule.SessionID); id != "" {
+			res.SessionID = id
+		}
+
+		switch rule.Type {
+		case EventInit:
+			emit(Event{Type: EventInit, SessionID: res.SessionID, Model: lookupString(event, rule.Model)})
+		case EventText:
+			content := lookupString(event, rule.Text)
+			text.WriteString(content)
+			emit(Event{Type: EventText, Text: content})
+		case EventToolUse:
+			input := map[string]any{"path": lookupString(event, rule.Path)}
+			toolUse := toolEvent(t.config.VaultPath, lookupString(event, rule.Tool), input)
+			if rule.Action != "" {
+				toolUse.Action = rule.Action
+			}
+			emit(toolUse)
+		case EventResult:
+			res.Response = lookupString(event, rule.Text)
+			emit(Event{Type: EventResult, SessionID: res.SessionID, Text: res.Response})
+		case eventError:
+			if *errMsg == "" {
+				*errMsg = lookupString(event, rule.Text)
+			}
+		}
+		return
+	}
+}
+
+// matches reports whether every match path of the rule has the required value
+func (r EventRule) matches(event any) bool {
+	for path, want := range r.Match {
+		if lookupString(event, path) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// env returns the template's own "KEY=value" variables, added to the allowlisted ones
+func (t *Template) env() []string {
+	var env []string
+	for _, key := range t.spec.Env {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	for key, value := range t.spec.SetEnv {
+		env = append(env, key+"="+value)
+	}
+	return env
+}
+
+// GetStartPrompt returns the prompt used for the /start command
+func (t *Template) GetStartPrompt() string {
+	return StartPrompt
+}
+
+// expandArgs substitutes placeholders in each argument in a single pass, so values
+// such as the prompt are never scanned for placeholders themselves
+func expandArgs(args []string, vars map[string]string) []string {
+	placeholders := make([]string, 0, len(vars))
+	for placeholder := range vars {
+		placeholders = append(placeholders, placeholder)
+	}
+	sort.Strings(placeholders)
+	pairs := make([]string, 0, 2*len(vars))
+	for _, placeholder := range placeholders {
+		pairs = append(pairs, placeholder, vars[placeholder])
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		expanded[i] = replacer.Replace(arg)
+	}
+	return expanded
+}
+
+// spliceArgs replaces the placeholder argument with list, or appends list if
+// the placeholder is absent
+func spliceArgs(args []string, placeholder string, list []string) []string {
+	for i, arg := range args {
+		if arg == placeholder {
+			return append(append(append([]string{}, args[:i]...), list...), args[i+1:]...)
+		}
+	}
+	return append(args, list...)
+}
+
+// lookupString follows a dotted path (object keys and array indexes) into decoded
+// JSON and returns the value as a string. Returns "" if the path is empty or missing.
+func lookupString(v any, path string) string {
+	if path == "" {
+		return ""
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return ""
+			}
+			v = node[i]
+		default:
+			return ""
+		}
+	}
+
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool, float64:
+		return fmt.Sprint(value)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
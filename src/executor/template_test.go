@@ -0,0 +1,242 @@
+//go:build unix
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExpandArgsLeavesPlaceholdersInValues(t *testing.T) {
+	vars := map[string]string{
+		"{prompt}":  "explain {vault}, {model} and {session} to me",
+		"{vault}":   "/vault",
+		"{model}":   "local",
+		"{session}": "s1",
+	}
+	args := []string{"-p", "{prompt}", "--dir={vault}", "{model}:{session}"}
+	want := []string{"-p", "explain {vault}, {model} and {session} to me", "--dir=/vault", "local:s1"}
+
+	// Map order is random; expand often enough to hit every order
+	for i := 0; i < 50; i++ {
+		if got := expandArgs(args, vars); !slices.Equal(got, want) {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+// fakeTemplateCLI installs a script named name on PATH that records its arguments,
+// prints output and exits with code. It returns the file holding the arguments.
+func fakeTemplateCLI(t *testing.T, name, output string, code int) string {
+	t.Helper()
+	bin := t.TempDir()
+	argsFile, outFile := filepath.Join(bin, name+".args"), filepath.Join(bin, name+".out")
+	writeFile(t, outFile, output)
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > '" + argsFile + "'\ncat '" + outFile + "'\nexit " + fmt.Sprint(code) + "\n"
+	if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return argsFile
+}
+
+// runTemplate runs a template for the fake CLI with output, collecting its events
+func runTemplate(t *testing.T, output string, code int, spec TemplateSpec, req Request) (*Result, []Event, string) {
+	t.Helper()
+	spec.Name, spec.Binary = "fake", "fake"
+	if spec.PermissionArgs == nil {
+		spec.PermissionArgs = map[PermissionMode][]string{ModeReadOnly: {"--read-only"}}
+	}
+	argsFile := fakeTemplateCLI(t, "fake", output, code)
+
+	var events []Event
+	res := NewTemplate(&Config{VaultPath: t.TempDir()}, spec).Execute(context.Background(), req, func(ev Event) {
+		events = append(events, ev)
+	})
+	args, _ := os.ReadFile(argsFile)
+	return res, events, string(args)
+}
+
+func TestTemplateJSONOutput(t *testing.T) {
+	output := OutputSpec{Format: OutputJSON, Result: "result", SessionID: "session_id", Error: "is_error"}
+	for _, tc := range []struct {
+		name      string
+		doc       string
+		output    OutputSpec
+		want      string // Response, or the error of a failed run
+		failed    bool
+		sessionID string
+	}{
+		{"result", `{"result": "hi", "session_id": "s-1", "is_error": false}`, output, "hi", false, "s-1"},
+		{"error true", `{"result": "model overloaded", "session_id": "s-1", "is_error": true}`, output, "model overloaded", true, "s-1"},
+		{"error message", `{"error": "bad request"}`, OutputSpec{Format: OutputJSON, Result: "result", Error: "error"}, "bad request", true, ""},
+		{"null error", `{"result": "ok", "error": null}`, OutputSpec{Format: OutputJSON, Result: "result", Error: "error"}, "ok", false, ""},
+		{"nested path", `{"message": {"content": [{"text": "a"}, {"text": "b"}]}}`,
+			OutputSpec{Format: OutputJSON, Result: "message.content.1.text"}, "b", false, ""},
+		{"missing result", `{"other": 1}`, output, "✅ Done (no output)", false, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, _, _ := runTemplate(t, tc.doc, 0, TemplateSpec{Output: tc.output}, Request{Prompt: "hi"})
+			got := res.Response
+			if res.Failed() {
+				got = res.Error
+			}
+			if res.Failed() != tc.failed || got != tc.want {
+				t.Errorf("got %q (failed: %v), want %q (failed: %v)", got, res.Failed(), tc.want, tc.failed)
+			}
+			if res.SessionID != tc.sessionID {
+				t.Errorf("session ID %q, want %q", res.SessionID, tc.sessionID)
+			}
+		})
+	}
+
+	res, _, _ := runTemplate(t, "not json", 0, TemplateSpec{Output: output}, Request{Prompt: "hi"})
+	if res.ErrorKind != ErrorParse {
+		t.Errorf("unparsable output failed with %q, want %q", res.ErrorKind, ErrorParse)
+	}
+}
+
+// ndjsonRules map the fake CLI's events; the catch-all text rule comes last
+var ndjsonRules = []EventRule{
+	{Match: map[string]string{"type": "init"}, Type: EventInit, SessionID: "session", Model: "model"},
+	{Match: map[string]string{"type": "item", "item.kind": "tool"}, Type: EventToolUse, Tool: "item.name", Path: "item.path"},
+	{Match: map[string]string{"type": "item"}, Type: EventText, Text: "item.text"},
+	{Match: map[string]string{"type": "failed"}, Type: eventError, Text: "message"},
+}
+
+func TestTemplateNDJSONRules(t *testing.T) {
+	output := strings.Join([]string{
+		`{"type": "init", "session": "s-9", "model": "big"}`,
+		`{"type": "item", "item": {"kind": "tool", "name": "Read", "path": "Notes/a.md", "text": "not a reply"}}`,
+		`not json`,
+		`{"type": "item", "item": {"kind": "text", "text": "Hel"}}`,
+		`{"type": "unknown", "text": "ignored"}`,
+		`{"type": "item", "item": {"kind": "text", "text": "lo"}}`,
+	}, "\n")
+
+	res, events, _ := runTemplate(t, output, 0, TemplateSpec{Output: OutputSpec{Format: OutputNDJSON, Events: ndjsonRules}}, Request{})
+	if res.Failed() {
+		t.Fatalf("run failed: %s", res.Error)
+	}
+	// Without a result rule, the text events are joined; the tool event only matched its own rule
+	if res.Response != "Hello" || res.SessionID != "s-9" || res.Model != "big" {
+		t.Errorf("got %q in session %q on %q", res.Response, res.SessionID, res.Model)
+	}
+	var types []EventType
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	if want := []EventType{EventInit, EventToolUse, EventText, EventText}; !slices.Equal(types, want) {
+		t.Errorf("events %v, want %v", types, want)
+	}
+	if tool := events[1]; tool.Tool != "Read" || tool.Action != ActionRead || tool.Path != "Notes/a.md" {
+		t.Errorf("tool event %+v", tool)
+	}
+
+	// A result rule replaces the joined text
+	rules := append([]EventRule{{Match: map[string]string{"type": "done"}, Type: EventResult, Text: "result"}}, ndjsonRules...)
+	res, _, _ = runTemplate(t, output+"\n"+`{"type": "done", "result": "Hello there"}`, 0,
+		TemplateSpec{Output: OutputSpec{Format: OutputNDJSON, Events: rules}}, Request{})
+	if res.Response != "Hello there" {
+		t.Errorf("got %q, want the result event's text", res.Response)
+	}
+
+	// An error rule fails the run with the first error
+	res, _, _ = runTemplate(t, output+"\n"+`{"type": "failed", "message": "boom"}`+"\n"+`{"type": "failed", "message": "later"}`, 0,
+		TemplateSpec{Output: OutputSpec{Format: OutputNDJSON, Events: ndjsonRules}}, Request{})
+	if !res.Failed() || res.Error != "boom" {
+		t.Errorf("got error %q (failed: %v), want boom", res.Error, res.Failed())
+	}
+
+	// Output without any JSON event doesn't match the format
+	res, _, _ = runTemplate(t, "plain text", 0, TemplateSpec{Output: OutputSpec{Format: OutputNDJSON, Events: ndjsonRules}}, Request{})
+	if res.ErrorKind != ErrorParse {
+		t.Errorf("unparsable output failed with %q, want %q", res.ErrorKind, ErrorParse)
+	}
+}
+
+func TestTemplateTextOutput(t *testing.T) {
+	res, _, _ := runTemplate(t, "\n  line one\nline two  \n\n", 0, TemplateSpec{Output: OutputSpec{Format: OutputText}}, Request{})
+	if res.Failed() || res.Response != "line one\nline two" {
+		t.Errorf("got %q (%s), want the trimmed output", res.Response, res.Error)
+	}
+
+	res, _, _ = runTemplate(t, "", 0, TemplateSpec{Output: OutputSpec{Format: OutputText}}, Request{})
+	if res.Response != "✅ Done (no output)" {
+		t.Errorf("got %q for no output", res.Response)
+	}
+
+	res, _, _ = runTemplate(t, "partial", 3, TemplateSpec{Output: OutputSpec{Format: OutputText}}, Request{})
+	if !res.Failed() {
+		t.Error("a failing exit code didn't fail the run")
+	}
+}
+
+func TestTemplateArguments(t *testing.T) {
+	spec := TemplateSpec{
+		Args:       []string{"run", "{permission_args}", "--dir", "{vault}", "{prompt}"},
+		ModelArgs:  []string{"--model", "{model}"},
+		ResumeArgs: []string{"--resume", "{session}"},
+		ForkArgs:   []string{"--fork"},
+		PermissionArgs: map[PermissionMode][]string{
+			ModeReadOnly: {"--sandbox", "read-only"},
+			ModeFull:     {"--sandbox", "off"},
+		},
+		Output: OutputSpec{Format: OutputText},
+	}
+
+	_, _, args := runTemplate(t, "ok", 0, spec, Request{Prompt: "hi", Model: "m1", SessionID: "s-1", Fork: true})
+	lines := strings.Split(strings.TrimSuffix(args, "\n"), "\n")
+	if len(lines) < 5 || !slices.Equal(lines[:3], []string{"run", "--sandbox", "read-only"}) || lines[3] != "--dir" {
+		t.Errorf("args %q, want the read-only permission arguments in place", lines)
+	}
+	if tail := strings.Join(lines[len(lines)-5:], " "); tail != "--model m1 --resume s-1 --fork" {
+		t.Errorf("args end with %q, want model and resume arguments appended", tail)
+	}
+
+	// A mode without arguments is refused before the CLI runs
+	res, _, args := runTemplate(t, "ok", 0, TemplateSpec{
+		PermissionArgs: map[PermissionMode][]string{ModeReadOnly: {}},
+		Output:         OutputSpec{Format: OutputText},
+	}, Request{Prompt: "hi", Mode: ModeAppend})
+	if !res.Failed() || !strings.Contains(res.Error, "no arguments for append mode") {
+		t.Errorf("got %q (failed: %v), want the run refused", res.Error, res.Failed())
+	}
+	if args != "" {
+		t.Errorf("CLI ran with %q", args)
+	}
+}
+
+func TestLookupString(t *testing.T) {
+	doc := map[string]any{
+		"items": []any{
+			map[string]any{"text": "first"},
+			map[string]any{"text": "second", "tags": []any{"a", "b"}},
+		},
+		"count":  float64(2),
+		"done":   true,
+		"object": map[string]any{"k": "v"},
+	}
+	for path, want := range map[string]string{
+		"items.0.text":   "first",
+		"items.1.tags.1": "b",
+		"items.2.text":   "",
+		"items.-1.text":  "",
+		"items.x":        "",
+		"count":          "2",
+		"done":           "true",
+		"object":         `{"k":"v"}`,
+		"object.k.z":     "",
+		"missing":        "",
+		"":               "",
+	} {
+		if got := lookupString(doc, path); got != want {
+			t.Errorf("lookupString(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
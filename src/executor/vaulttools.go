@@ -0,0 +1,380 @@
+// Package executor provides vault tools for executors that run their own tool loop.
+package executor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxReadBytes bounds the content returned by read_file
+	maxReadBytes = 100 * 1024
+	// maxSearchMatches bounds the lines returned by search_files
+	maxSearchMatches = 50
+)
+
+var (
+	// errOutsideVault is returned for paths that resolve outside the vault
+	errOutsideVault = errors.New("path is outside the vault")
+	// errNotNote is returned for writes to anything but a Markdown note outside hidden
+	// folders, which would let the model change Obsidian's config or plugin code
+	errNotNote = errors.New("only Markdown notes (.md) outside hidden folders can be written")
+)
+
+// vaultTools implements file tools strictly confined to a vault directory
+type vaultTools struct {
+	root    string          // Absolute vault path with symlinks resolved
+	allowed map[string]bool // Tools permitted by the permission mode
+}
+
+// newVaultTools creates tools confined to vaultPath, limited to those the mode permits
+func newVaultTools(vaultPath string, mode PermissionMode) (*vaultTools, error) {
+	allowed := make(map[string]bool)
+	for _, name := range vaultToolsByMode[mode.orReadOnly()] {
+		allowed[name] = true
+	}
+
+	root, err := filepath.Abs(vaultPath)
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	return &vaultTools{root: root, allowed: allowed}, nil
+}
+
+// specs returns the specs of the permitted tools
+func (t *vaultTools) specs() []toolSpec {
+	var specs []toolSpec
+	for _, spec := range vaultToolSpecs {
+		if t.allowed[spec.Function.Name] {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// toolSpec describes a tool to the model in OpenAI function-calling format
+type toolSpec struct {
+	Type     string       `json:"type"`
+	Function toolFunction `json:"function"`
+}
+
+// toolFunction is the function part of a tool spec
+type toolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+// vaultToolSpecs are the tools offered to the model
+var vaultToolSpecs = []toolSpec{
+	newToolSpec("read_file", "Read a note from the vault.", map[string]string{
+		"path": "Path relative to the vault root, e.g. Projects/Alpha.md",
+	}, "path"),
+	newToolSpec("write_file", "Create or overwrite a Markdown note (.md) in the vault. Parent folders are created as needed.", map[string]string{
+		"path":    "Path relative to the vault root",
+		"content": "Full Markdown content of the note",
+	}, "path", "content"),
+	newToolSpec("append_file", "Append text to the end of a Markdown note (.md), creating it if missing.", map[string]string{
+		"path":    "Path relative to the vault root",
+		"content": "Markdown text to append",
+	}, "path", "content"),
+	newToolSpec("list_directory", "List files and folders in a vault folder. Folders end with a slash.", map[string]string{
+		"path": "Folder relative to the vault root (empty for the root)",
+	}),
+	newToolSpec("search_files", "Case-insensitive text search across Markdown notes. Returns matching lines as path:line: text.", map[string]string{
+		"query": "Text to search for",
+		"path":  "Folder to search, relative to the vault root (empty for the whole vault)",
+	}, "query"),
+}
+
+// newToolSpec builds a tool spec with string parameters
+func newToolSpec(name, description string, params map[string]string, required ...string) toolSpec {
+	properties := make(map[string]any, len(params))
+	for param, desc := range params {
+		properties[param] = map[string]string{"type": "string", "description": desc}
+	}
+	if required == nil {
+		required = []string{}
+	}
+	return toolSpec{
+		Type: "function",
+		Function: toolFunction{
+			Name:        name,
+			Description: description,
+			Parameters: map[string]any{
+				"type":       "object",
+				"properties": properties,
+				"required":   required,
+			},
+		},
+	}
+}
+
+// vaultToolActions maps vault tool names to their actions for progress events
+var vaultToolActions = map[string]ToolAction{
+	"read_file":      ActionRead,
+	"write_file":     ActionWrite,
+	"append_file":    ActionEdit,
+	"list_directory": ActionList,
+	"search_files":   ActionSearch,
+}
+
+// toolArgs are the arguments of any vault tool call
+type toolArgs struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	Query   string `json:"query"`
+}
+
+// call runs a tool with JSON-encoded arguments. Errors are returned as text
+// so the model can see and recover from them.
+func (t *vaultTools) call(name, arguments string) string {
+	var args toolArgs
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return fmt.Sprintf("error: invalid arguments: %v", err)
+		}
+	}
+
+	if !t.allowed[name] {
+		return fmt.Sprintf("error: %s is not permitted in the current permission mode", name)
+	}
+
+	var out string
+	var err error
+	switch name {
+	case "read_file":
+		out, err = t.read(args.Path)
+	case "write_file":
+		out, err = t.write(args.Path, args.Content, false)
+	case "append_file":
+		out, err = t.write(args.Path, args.Content, true)
+	case "list_directory":
+		out, err = t.list(args.Path)
+	case "search_files":
+		out, err = t.search(args.Query, args.Path)
+	default:
+		err = fmt.Errorf("unknown tool %q", name)
+	}
+
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return out
+}
+
+// resolve maps a vault-relative path to an absolute path inside the vault.
+// Absolute paths are accepted only if they lie inside the vault; ".." escapes
+// and symlinks pointing outside the vault are rejected.
+func (t *vaultTools) resolve(path string) (string, error) {
+	var abs string
+	if filepath.IsAbs(path) {
+		abs = filepath.Clean(path)
+	} else {
+		abs = filepath.Join(t.root, path)
+	}
+	if !t.inside(abs) {
+		return "", errOutsideVault
+	}
+
+	// Resolve symlinks on the longest existing prefix (the target may not exist yet)
+	existing := abs
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	if !t.inside(resolved) {
+		return "", errOutsideVault
+	}
+
+	return abs, nil
+}
+
+// inside reports whether abs is the vault root or below it
+func (t *vaultTools) inside(abs string) bool {
+	rel, err := filepath.Rel(t.root, abs)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isNote reports whether abs is a Markdown note outside hidden folders such as .obsidian,
+// and, if it is a symlink, whether its target is one too
+func (t *vaultTools) isNote(abs string) bool {
+	paths := []string{abs}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		paths = append(paths, resolved)
+	}
+	for _, p := range paths {
+		rel := t.relative(p)
+		if !strings.EqualFold(filepath.Ext(rel), ".md") {
+			return false
+		}
+		for _, segment := range strings.Split(rel, "/") {
+			if strings.HasPrefix(segment, ".") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// relative returns abs relative to the vault root, for tool output
+func (t *vaultTools) relative(abs string) string {
+	rel, err := filepath.Rel(t.root, abs)
+	if err != nil {
+		return abs
+	}
+	return filepath.ToSlash(rel)
+}
+
+// read returns the content of a note, truncated to maxReadBytes
+func (t *vaultTools) read(path string) (string, error) {
+	abs, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxReadBytes {
+		return string(data[:maxReadBytes]) + "\n\n[truncated]", nil
+	}
+	return string(data), nil
+}
+
+// write creates, overwrites or appends to a note
+func (t *vaultTools) write(path, content string, appendMode bool) (string, error) {
+	if path == "" {
+		return "", errors.New("path is required")
+	}
+	abs, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if !t.isNote(abs) {
+		return "", errNotNote
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return "", err
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendMode {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(abs, flags, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("ok: wrote %d bytes to %s", len(content), t.relative(abs)), nil
+}
+
+// list returns the entries of a folder, skipping hidden ones such as .obsidian
+func (t *vaultTools) list(path string) (string, error) {
+	abs, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return "(empty)", nil
+	}
+	return strings.Join(names, "\n"), nil
+}
+
+// search finds lines containing query in Markdown notes below path
+func (t *vaultTools) search(query, path string) (string, error) {
+	if query == "" {
+		return "", errors.New("query is required")
+	}
+	abs, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+
+	needle := strings.ToLower(query)
+	var matches []string
+	errLimit := errors.New("limit reached")
+
+	err = filepath.WalkDir(abs, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if d.IsDir() {
+			if p != abs && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(strings.ToLower(d.Name()), ".md") {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.Contains(strings.ToLower(scanner.Text()), needle) {
+				matches = append(matches, fmt.Sprintf("%s:%d: %s", t.relative(p), line, strings.TrimSpace(scanner.Text())))
+				if len(matches) >= maxSearchMatches {
+					return errLimit
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimit) {
+		return "", err
+	}
+
+	if len(matches) == 0 {
+		return "no matches", nil
+	}
+	return strings.Join(matches, "\n"), nil
+}
@@ -0,0 +1,193 @@
+//go:build unix
+
+package executor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testVault creates a vault with a note, next to an outside folder holding a secret,
+// and symlinks in the vault pointing at the outside folder and the secret
+func testVault(t *testing.T) (vault, outside string) {
+	t.Helper()
+	dir := t.TempDir()
+	vault, outside = filepath.Join(dir, "vault"), filepath.Join(dir, "outside")
+	for _, folder := range []string{filepath.Join(vault, "Notes"), outside} {
+		if err := os.MkdirAll(folder, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(vault, "Notes", "a.md"), "alpha\n")
+	writeFile(t, filepath.Join(outside, "secret.md"), "secret\n")
+	if err := os.Symlink(outside, filepath.Join(vault, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.md"), filepath.Join(vault, "link.md")); err != nil {
+		t.Fatal(err)
+	}
+	return vault, outside
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// toolCall encodes tool arguments as the model sends them
+func toolCall(t *testing.T, args map[string]string) string {
+	t.Helper()
+	data, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestVaultToolsRejectEscapes(t *testing.T) {
+	vault, outside := testVault(t)
+	plugin := filepath.Join(vault, ".obsidian", "plugins", "x", "main.js")
+	if err := os.MkdirAll(filepath.Dir(plugin), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, plugin, "plugin\n")
+	if err := os.Symlink(plugin, filepath.Join(vault, "plugin.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		mode PermissionMode
+		tool string
+		args map[string]string
+		want string // Substring of the error
+	}{
+		{"dot-dot read", ModeFull, "read_file", map[string]string{"path": "../outside/secret.md"}, "outside the vault"},
+		{"nested dot-dot read", ModeFull, "read_file", map[string]string{"path": "Notes/../../outside/secret.md"}, "outside the vault"},
+		{"dot-dot write", ModeFull, "write_file", map[string]string{"path": "../outside/new.md", "content": "x"}, "outside the vault"},
+		{"dot-dot append", ModeAppend, "append_file", map[string]string{"path": "../outside/secret.md", "content": "x"}, "outside the vault"},
+		{"dot-dot list", ModeFull, "list_directory", map[string]string{"path": ".."}, "outside the vault"},
+		{"dot-dot search", ModeFull, "search_files", map[string]string{"query": "secret", "path": "../outside"}, "outside the vault"},
+		{"absolute read", ModeFull, "read_file", map[string]string{"path": filepath.Join(outside, "secret.md")}, "outside the vault"},
+		{"absolute write", ModeFull, "write_file", map[string]string{"path": filepath.Join(outside, "new.md"), "content": "x"}, "outside the vault"},
+		{"absolute root", ModeFull, "list_directory", map[string]string{"path": "/"}, "outside the vault"},
+		{"symlinked folder read", ModeFull, "read_file", map[string]string{"path": "linkdir/secret.md"}, "outside the vault"},
+		{"symlinked folder write", ModeFull, "write_file", map[string]string{"path": "linkdir/new.md", "content": "x"}, "outside the vault"},
+		{"symlinked folder create below", ModeFull, "write_file", map[string]string{"path": "linkdir/sub/new.md", "content": "x"}, "outside the vault"},
+		{"symlinked folder list", ModeFull, "list_directory", map[string]string{"path": "linkdir"}, "outside the vault"},
+		{"symlinked folder search", ModeFull, "search_files", map[string]string{"query": "secret", "path": "linkdir"}, "outside the vault"},
+		{"symlinked file read", ModeFull, "read_file", map[string]string{"path": "link.md"}, "outside the vault"},
+		{"symlinked file write", ModeFull, "write_file", map[string]string{"path": "link.md", "content": "x"}, "outside the vault"},
+		{"symlinked file append", ModeAppend, "append_file", map[string]string{"path": "link.md", "content": "x"}, "outside the vault"},
+		{"read-only write", ModeReadOnly, "write_file", map[string]string{"path": "Notes/a.md", "content": "x"}, "not permitted"},
+		{"read-only append", ModeReadOnly, "append_file", map[string]string{"path": "Notes/a.md", "content": "x"}, "not permitted"},
+		{"read-only create", ModeReadOnly, "write_file", map[string]string{"path": "Notes/new.md", "content": "x"}, "not permitted"},
+		{"read-only create by append", ModeReadOnly, "append_file", map[string]string{"path": "Notes/new.md", "content": "x"}, "not permitted"},
+		{"default mode write", "", "write_file", map[string]string{"path": "Notes/a.md", "content": "x"}, "not permitted"},
+		{"append mode overwrite", ModeAppend, "write_file", map[string]string{"path": "Notes/a.md", "content": "x"}, "not permitted"},
+		{"unknown tool", ModeFull, "delete_file", map[string]string{"path": "Notes/a.md"}, "not permitted"},
+		{"append to plugin code", ModeAppend, "append_file", map[string]string{"path": ".obsidian/plugins/x/main.js", "content": "x"}, "only Markdown notes"},
+		{"overwrite config", ModeFull, "write_file", map[string]string{"path": ".obsidian/app.json", "content": "{}"}, "only Markdown notes"},
+		{"note in hidden folder", ModeFull, "write_file", map[string]string{"path": ".obsidian/notes.md", "content": "x"}, "only Markdown notes"},
+		{"hidden note", ModeAppend, "append_file", map[string]string{"path": "Notes/.hidden.md", "content": "x"}, "only Markdown notes"},
+		{"absolute hidden path", ModeAppend, "append_file", map[string]string{"path": filepath.Join(vault, ".trash", "a.md"), "content": "x"}, "only Markdown notes"},
+		{"script", ModeFull, "write_file", map[string]string{"path": "Notes/script.js", "content": "x"}, "only Markdown notes"},
+		{"no extension", ModeAppend, "append_file", map[string]string{"path": "Notes/a", "content": "x"}, "only Markdown notes"},
+		{"note symlinked to plugin code", ModeAppend, "append_file", map[string]string{"path": "plugin.md", "content": "x"}, "only Markdown notes"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tools, err := newVaultTools(vault, tc.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := tools.call(tc.tool, toolCall(t, tc.args))
+			if !strings.HasPrefix(out, "error: ") || !strings.Contains(out, tc.want) {
+				t.Errorf("got %q, want an error containing %q", out, tc.want)
+			}
+			if strings.Contains(out, "secret") {
+				t.Errorf("leaked outside content: %q", out)
+			}
+		})
+	}
+
+	// Nothing outside the vault was created or changed, and the vault note is intact
+	entries, err := os.ReadDir(outside)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("outside folder has %d entries (%v), want only the secret", len(entries), err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(outside, "secret.md")); string(data) != "secret\n" {
+		t.Errorf("secret was changed to %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(vault, "Notes", "a.md")); string(data) != "alpha\n" {
+		t.Errorf("note was changed to %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(vault, "Notes", "new.md")); !os.IsNotExist(err) {
+		t.Error("a note was created in read-only mode")
+	}
+	if data, _ := os.ReadFile(plugin); string(data) != "plugin\n" {
+		t.Errorf("plugin code was changed to %q", data)
+	}
+	for _, path := range []string{".obsidian/app.json", ".obsidian/notes.md", "Notes/.hidden.md", ".trash", "Notes/script.js", "Notes/a"} {
+		if _, err := os.Lstat(filepath.Join(vault, path)); !os.IsNotExist(err) {
+			t.Errorf("%s was created", path)
+		}
+	}
+}
+
+func TestVaultToolsAllowVaultPaths(t *testing.T) {
+	vault, _ := testVault(t)
+
+	for _, tc := range []struct {
+		name string
+		mode PermissionMode
+		tool string
+		args map[string]string
+		want string // Substring of the output
+	}{
+		{"relative read", ModeReadOnly, "read_file", map[string]string{"path": "Notes/a.md"}, "alpha"},
+		{"absolute read inside", ModeReadOnly, "read_file", map[string]string{"path": filepath.Join(vault, "Notes", "a.md")}, "alpha"},
+		{"dot-dot staying inside", ModeReadOnly, "read_file", map[string]string{"path": "Notes/../Notes/a.md"}, "alpha"},
+		{"list root", ModeReadOnly, "list_directory", map[string]string{}, "Notes/"},
+		{"search", ModeReadOnly, "search_files", map[string]string{"query": "ALPHA"}, "Notes/a.md:1: alpha"},
+		{"append", ModeAppend, "append_file", map[string]string{"path": "Notes/a.md", "content": "beta\n"}, "ok: wrote"},
+		{"create by append", ModeAppend, "append_file", map[string]string{"path": "Inbox/today.md", "content": "- milk\n"}, "ok: wrote"},
+		{"write", ModeFull, "write_file", map[string]string{"path": "Projects/new.md", "content": "# New\n"}, "ok: wrote 6 bytes to Projects/new.md"},
+		{"upper-case extension", ModeAppend, "append_file", map[string]string{"path": "Inbox/Later.MD", "content": "- eggs\n"}, "ok: wrote"},
+		{"dotted note name", ModeFull, "write_file", map[string]string{"path": "Notes/v1.2 plan.md", "content": "x"}, "ok: wrote"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tools, err := newVaultTools(vault, tc.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out := tools.call(tc.tool, toolCall(t, tc.args)); !strings.Contains(out, tc.want) {
+				t.Errorf("got %q, want %q", out, tc.want)
+			}
+		})
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(vault, "Notes", "a.md")); string(data) != "alpha\nbeta\n" {
+		t.Errorf("note is %q, want the appended line", data)
+	}
+}
+
+func TestVaultToolsOfferOnlyPermittedTools(t *testing.T) {
+	for mode, want := range vaultToolsByMode {
+		tools, err := newVaultTools(t.TempDir(), mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, spec := range tools.specs() {
+			names = append(names, spec.Function.Name)
+		}
+		if len(names) != len(want) {
+			t.Errorf("%s mode offers %v, want %v", mode, names, want)
+		}
+	}
+}
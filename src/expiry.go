@@ -0,0 +1,237 @@
+// Package main provides idle expiry and daily rollover of sessions into summary notes.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gpng/obsidian-pa/src/executor"
+	"github.com/gpng/obsidian-pa/src/sessions"
+)
+
+// expiryInterval is how often sessions are checked for expiry
+const expiryInterval = time.Minute
+
+// SummaryPrompt asks the AI to summarize a session before it expires
+const SummaryPrompt = `This conversation is ending. Write a summary of it as a Markdown note for my vault:
+what we discussed, decisions made, notes created or changed (as [[wikilinks]]), and open questions or next steps.
+Reply with the note body only - no frontmatter, no preamble - and don't change any files.`
+
+// SessionExpiry configures when sessions expire and where their summaries are filed
+type SessionExpiry struct {
+	IdleTimeout time.Duration  // Expire sessions unused for this long (0 = never)
+	Rollover    string         // Expire sessions unused since this local time of day, "HH:MM" ("" = never)
+	Location    *time.Location // Timezone of Rollover and summary dates (nil = local)
+	SummaryDir  string         // Folder for summary notes, relative to the vault
+	VaultPath   string         // Vault root
+}
+
+// location returns the timezone of rollovers and summary dates
+func (e SessionExpiry) location() *time.Location {
+	if e.Location == nil {
+		return time.Local
+	}
+	return e.Location
+}
+
+// Enabled reports whether sessions expire at all
+func (e SessionExpiry) Enabled() bool {
+	return e.IdleTimeout > 0 || e.Rollover != ""
+}
+
+// expired reports whether a session last used at lastUsed has expired at now
+func (e SessionExpiry) expired(lastUsed, now time.Time) bool {
+	if e.IdleTimeout > 0 && now.Sub(lastUsed) >= e.IdleTimeout {
+		return true
+	}
+	if e.Rollover != "" {
+		return lastUsed.Before(lastRollover(e.Rollover, now.In(e.location())))
+	}
+	return false
+}
+
+// lastRollover returns the most recent occurrence of the "HH:MM" local time at or before now
+func lastRollover(clock string, now time.Time) time.Time {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}
+	}
+	rollover := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if rollover.After(now) {
+		rollover = rollover.AddDate(0, 0, -1)
+	}
+	return rollover
+}
+
+// runExpiry periodically summarizes and clears expired sessions (blocking)
+func (a *App) runExpiry() {
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		expired := a.Sessions.Expired(func(s sessions.Session) bool {
+			return a.Expiry.expired(s.LastUsed, now)
+		})
+		for _, session := range expired {
+			// Sessions with a run queued or in flight are left for the next check
+			if a.jobs.busy(session.Key) || a.batches.collecting(session.Key) {
+				continue
+			}
+			platform, _, _ := strings.Cut(session.Key, ":")
+			a.jobs.submit(session.Key, platform, "Session summary", func(ctx context.Context) {
+				a.expireSession(ctx, session)
+			})
+		}
+	}
+}
+
+// expireSession files a summary of a session in the vault and clears it.
+// It runs as a queued job, so it never overlaps a run in the same session.
+func (a *App) expireSession(ctx context.Context, session sessions.ExpiredSession) {
+	// Skip sessions used or cleared since they were found
+	if current, ok := a.Sessions.Find(session.Key, session.Name); !ok || !current.LastUsed.Equal(session.LastUsed) {
+		return
+	}
+
+	path, err := a.summarizeSession(ctx, session)
+	var transient interface{ Transient() bool }
+	if errors.Is(err, errBudgetReached) || errors.As(err, &transient) && transient.Transient() {
+		// Keep the session until the executor is back or the budget allows runs again
+		log.Printf("Session %s/%s expired, summary postponed: %v", session.Key, session.Name, err)
+		return
+	}
+	switch {
+	case err != nil:
+		log.Printf("Session %s/%s expired without summary: %v", session.Key, session.Name, err)
+	case path != "":
+		log.Printf("Session %s/%s expired, summary saved to %s", session.Key, session.Name, path)
+	}
+
+	if err := a.Sessions.Clear(session.Key, session.Name); err != nil {
+		log.Printf("Failed to save expired session: %v", err)
+	}
+}
+
+// errBudgetReached postpones summaries while a budget cap pauses runs
+var errBudgetReached = errors.New("budget cap reached")
+
+// summaryError is a failed summary run
+type summaryError struct {
+	res *executor.Result
+}
+
+func (e *summaryError) Error() string {
+	return fmt.Sprintf("%s run failed (%s): %s", e.res.Executor, e.res.ErrorKind, truncate(e.res.Error, maxErrorDetail))
+}
+
+// Transient reports whether the summary may succeed later
+func (e *summaryError) Transient() bool {
+	return e.res.ErrorKind.Transient()
+}
+
+// summarizeSession asks the session's last executor for a summary and writes it to a note.
+// Returns the note's vault-relative path, or "" if the session had nothing to summarize.
+func (a *App) summarizeSession(ctx context.Context, session sessions.ExpiredSession) (string, error) {
+	if len(session.IDs) == 0 {
+		return "", nil // Forked but never used
+	}
+	if a.checkBudget() != "" {
+		return "", errBudgetReached
+	}
+
+	// Continue on the executor and model that answered last, if still configured
+	chain := a.Registry.Chain(session.Executor)
+	model := session.Model
+	if chain.Primary().Name() != session.Executor {
+		model = ""
+	}
+
+	// Summarize in the session itself, without write access; the bot writes the note
+	req := executor.Request{Prompt: SummaryPrompt, Model: model, Mode: executor.ModeReadOnly}
+	res := chain.Execute(ctx, req, session.IDs, session.Forks, nil)
+	platform, _, _ := strings.Cut(session.Key, ":")
+	a.recordUsage(platform, res)
+	if res.Failed() {
+		return "", &summaryError{res: res}
+	}
+
+	return a.writeSummary(session, res)
+}
+
+// writeSummary files a session summary as a dated note, e.g. "PA/Sessions/2026-10-17 planning.md"
+func (a *App) writeSummary(session sessions.ExpiredSession, res *executor.Result) (string, error) {
+	dir := filepath.Join(a.Expiry.VaultPath, filepath.FromSlash(a.Expiry.SummaryDir))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	title := session.Name
+	if title == sessions.DefaultName && session.Title != "" {
+		title = session.Title
+	}
+	base := session.LastUsed.In(a.Expiry.location()).Format("2006-01-02") + " " + noteFileName(title)
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "session: %q\n", session.Name)
+	if session.Title != "" {
+		fmt.Fprintf(&b, "title: %q\n", session.Title)
+	}
+	fmt.Fprintf(&b, "conversation: %q\n", session.Key)
+	fmt.Fprintf(&b, "executor: %q\n", res.Executor)
+	if res.Model != "" {
+		fmt.Fprintf(&b, "model: %q\n", res.Model)
+	}
+	fmt.Fprintf(&b, "started: %s\n", session.Created.In(a.Expiry.location()).Format(time.RFC3339))
+	fmt.Fprintf(&b, "last_used: %s\n", session.LastUsed.In(a.Expiry.location()).Format(time.RFC3339))
+	b.WriteString("tags: [pa/session]\n")
+	b.WriteString("---\n\n")
+	b.WriteString(strings.TrimSpace(res.Response))
+	b.WriteString("\n")
+
+	// Never overwrite an earlier summary with the same name
+	for i := 1; ; i++ {
+		name := base + ".md"
+		if i > 1 {
+			name = fmt.Sprintf("%s %d.md", base, i)
+		}
+		file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := file.WriteString(b.String()); err != nil {
+			file.Close()
+			return "", err
+		}
+		return filepath.ToSlash(filepath.Join(a.Expiry.SummaryDir, name)), file.Close()
+	}
+}
+
+// noteFileName makes a title safe for use as a note file name
+func noteFileName(title string) string {
+	title = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|#^[]`, r) || r < ' ' {
+			return ' '
+		}
+		return r
+	}, title)
+	title = strings.Join(strings.Fields(title), " ")
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = strings.TrimSpace(string(runes[:maxTitleLength]))
+	}
+	title = strings.TrimRight(title, ".…")
+	if title == "" {
+		title = "session"
+	}
+	return title
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gpng/obsidian-pa/src/sessions"
+	"github.com/gpng/obsidian-pa/src/usage"
+)
+
+func TestRolloverUsesConfiguredTimezone(t *testing.T) {
+	now := time.Date(2026, 10, 17, 20, 0, 0, 0, time.UTC)
+	lastUsed := now.Add(-3 * time.Hour)
+
+	// 04:00 in UTC+10 was 18:00 UTC, after the session was last used
+	ahead := SessionExpiry{Rollover: "04:00", Location: time.FixedZone("UTC+10", 10*60*60)}
+	if !ahead.expired(lastUsed, now) {
+		t.Error("session used before the 04:00 rollover in UTC+10 didn't expire")
+	}
+	utc := SessionExpiry{Rollover: "04:00", Location: time.UTC}
+	if utc.expired(lastUsed, now) {
+		t.Error("session used after the 04:00 rollover in UTC expired")
+	}
+}
+
+func TestExpiryWaitsForBudget(t *testing.T) {
+	app, echo := newTestApp(t)
+	vault := t.TempDir()
+	app.Expiry = SessionExpiry{IdleTimeout: time.Hour, SummaryDir: "PA/Sessions", VaultPath: vault}
+
+	run, _ := app.Sessions.Start("fake:chat")
+	if err := app.Sessions.Record(run, "echo", "", "session-1", "Plans"); err != nil {
+		t.Fatal(err)
+	}
+	session, _ := app.Sessions.Find("fake:chat", sessions.DefaultName)
+	expired := sessions.ExpiredSession{Key: "fake:chat", Session: session}
+
+	// Over the daily cap, the session is kept and no summary is run
+	usageDir := t.TempDir()
+	capped, err := usage.NewLedger(usageDir, usage.Budget{DailyUSD: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := capped.Add(usage.Record{Time: time.Now(), Executor: "echo", CostUSD: 2}); err != nil {
+		t.Fatal(err)
+	}
+	app.Ledger = capped
+	app.expireSession(context.Background(), expired)
+	if len(echo.requests) != 0 || !app.Sessions.Get("fake:chat").Active() {
+		t.Fatalf("expired a session over budget (%d runs)", len(echo.requests))
+	}
+
+	// Once runs are allowed again, the summary is filed and the session cleared
+	app.Ledger, err = usage.NewLedger(usageDir, usage.Budget{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.expireSession(context.Background(), expired)
+	if len(echo.requests) != 1 || app.Sessions.Get("fake:chat").Active() {
+		t.Errorf("session wasn't expired after the budget allowed it (%d runs)", len(echo.requests))
+	}
+	if notes, _ := os.ReadDir(filepath.Join(vault, "PA", "Sessions")); len(notes) != 1 {
+		t.Errorf("filed %d summaries, want one", len(notes))
+	}
+}
@@ -0,0 +1,133 @@
+// Package main provides the shared session and /handoff between messaging platforms.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/gpng/obsidian-pa/src/executor"
+)
+
+// sharedSessionKey is the session key of the direct chats when SHARED_SESSION links them
+const sharedSessionKey = "shared"
+
+// HandoffPrompt asks the AI for a recap of the conversation to post on the other platform
+const HandoffPrompt = `I'm moving this conversation to another device. Write a short recap of it:
+what we discussed, what was decided or changed in the vault, and any open questions or next steps.
+Keep it under 10 bullet points and don't change any files.`
+
+// homeChat is a platform's direct chat with the allowed user, where handoffs are posted
+type homeChat struct {
+	name string            // Platform display name, e.g. "Slack"
+	key  string            // Session key of the chat
+	send func(text string) // Posts a message to the chat
+}
+
+// directSessionKey returns the session key of a platform's direct chat with the allowed
+// user: the shared key when SHARED_SESSION links the platforms, otherwise its own key
+func (a *App) directSessionKey(key string) string {
+	if a.SharedSession {
+		return sharedSessionKey
+	}
+	return key
+}
+
+// registerHome makes a platform's direct chat available as a /handoff target
+func (a *App) registerHome(platform, name, key string, send func(text string)) {
+	a.homesMu.Lock()
+	defer a.homesMu.Unlock()
+
+	if a.homes == nil {
+		a.homes = make(map[string]homeChat)
+	}
+	a.homes[platform] = homeChat{name: name, key: key, send: send}
+}
+
+// handoffTarget picks the platform to hand off to: the named one, or the only other one
+func (a *App) handoffTarget(from, arg string) (homeChat, string) {
+	a.homesMu.Lock()
+	defer a.homesMu.Unlock()
+
+	if arg != "" {
+		home, ok := a.homes[strings.ToLower(arg)]
+		if !ok || strings.EqualFold(arg, from) {
+			return homeChat{}, fmt.Sprintf("❌ Can't hand off to %s. Use one of: %s.", arg, a.otherHomes(from))
+		}
+		return home, ""
+	}
+
+	var others []homeChat
+	for platform, home := range a.homes {
+		if platform != from {
+			others = append(others, home)
+		}
+	}
+	switch len(others) {
+	case 0:
+		return homeChat{}, "❌ Handoff needs another platform. Configure Telegram, Slack or Discord alongside this one."
+	case 1:
+		return others[0], ""
+	}
+	return homeChat{}, fmt.Sprintf("❌ Hand off to which platform? Use /handoff <%s>.", a.otherHomes(from))
+}
+
+// otherHomes lists the platforms other than from. Must be called with homesMu held.
+func (a *App) otherHomes(from string) string {
+	var names []string
+	for platform := range a.homes {
+		if platform != from {
+			names = append(names, platform)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// handoff moves a conversation's active session to another platform's direct chat and
+// posts a recap there. Returns the reply for the chat the handoff was requested in.
+func (a *App) handoff(ctx context.Context, from, sessionKey, chatKey, arg string) string {
+	target, refusal := a.handoffTarget(from, arg)
+	if refusal == "" {
+		refusal = a.checkBudget()
+	}
+	if refusal != "" {
+		return refusal
+	}
+	if !a.Sessions.Get(sessionKey).Active() {
+		return "ℹ️ Nothing to hand off yet - this conversation has no session."
+	}
+
+	// Ask for the recap in the session itself, without write access
+	chain, req := a.prepareRun(chatKey, HandoffPrompt)
+	req.Mode = executor.ModeReadOnly
+	run, ids, forks := a.resumeSessions(sessionKey)
+	res := chain.Execute(ctx, req, ids, forks, nil)
+	a.recordUsage(from, res)
+	a.recordSession(run, res, "")
+	if res.Failed() {
+		return failureMessage(res)
+	}
+
+	// Shared direct chats already have the session
+	if target.key != sessionKey {
+		if err := a.Sessions.Copy(sessionKey, target.key); err != nil {
+			log.Printf("Failed to save handed off session: %v", err)
+			return fmt.Sprintf("❌ Failed to hand off session: %v", err)
+		}
+	}
+
+	log.Printf("[%s] Handed off session %s to %s", from, sessionKey, target.name)
+	target.send(fmt.Sprintf("🤝 **Continued from %s**\n\n%s\n\n_Reply here to pick up where you left off._", platformName(from), res.Response))
+	return fmt.Sprintf("✅ Handed off to %s with a recap. Continue there.", target.name)
+}
+
+// platformName returns a platform's display name, e.g. "Telegram"
+func platformName(platform string) string {
+	if platform == "" {
+		return ""
+	}
+	return strings.ToUpper(platform[:1]) + platform[1:]
+}
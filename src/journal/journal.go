@@ -0,0 +1,100 @@
+// Package journal appends chat exchanges to dated Markdown logs in the vault.
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is one exchange: a prompt and the AI's answer
+type Entry struct {
+	Asked        time.Time // When the prompt was received
+	Answered     time.Time // When the answer was ready
+	Platform     string    // e.g. "Telegram"
+	Conversation string    // Session key of the conversation
+	Session      string    // Session name, e.g. "main"
+	Executor     string    // Executor that answered
+	Model        string
+	SessionID    string // CLI session ID
+	Prompt       string
+	Response     string // Answer, or the error shown to the user
+	Failed       bool
+}
+
+// Journal writes entries to one note per day, e.g. "PA/Journal/2026-10-17.md"
+type Journal struct {
+	dir string
+
+	mu sync.Mutex
+}
+
+// New creates a journal writing to dir
+func New(dir string) *Journal {
+	return &Journal{dir: dir}
+}
+
+// Add appends an entry to the note of the day it was asked
+func (j *Journal) Add(e Entry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := os.MkdirAll(j.dir, 0o755); err != nil {
+		return err
+	}
+
+	day := e.Asked.Format("2006-01-02")
+	path := filepath.Join(j.dir, day+".md")
+
+	var b strings.Builder
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(&b, "---\ndate: %s\ntags: [pa/journal]\n---\n\n# PA Journal %s\n", day, day)
+	}
+
+	fmt.Fprintf(&b, "\n## %s · %s · %s\n\n", e.Asked.Format("15:04"), e.Platform, e.Session)
+	writeCallout(&b, fmt.Sprintf("[!question] You · %s", e.Asked.Format("15:04:05")), e.Prompt)
+	b.WriteString("\n")
+
+	kind := "[!quote]"
+	if e.Failed {
+		kind = "[!failure]"
+	}
+	writeCallout(&b, fmt.Sprintf("%s %s · %s", kind, e.Executor, e.Answered.Format("15:04:05")), e.Response)
+	b.WriteString("\n")
+
+	meta := []string{
+		"executor:: " + e.Executor,
+		"model:: " + e.Model,
+		"session:: " + e.Session,
+		"session_id:: " + e.SessionID,
+		"conversation:: " + e.Conversation,
+		"duration:: " + e.Answered.Sub(e.Asked).Round(time.Second).String(),
+	}
+	writeCallout(&b, "[!info]- Details", strings.Join(meta, "\n"))
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(b.String()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeCallout writes an Obsidian callout, quoting every line of body
+func writeCallout(b *strings.Builder, header, body string) {
+	fmt.Fprintf(b, "> %s\n", header)
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		if line == "" {
+			b.WriteString(">\n")
+			continue
+		}
+		fmt.Fprintf(b, "> %s\n", line)
+	}
+}
@@ -1,14 +1,22 @@
 // Package main implements a multi-platform bot that bridges user messages to AI CLI
-// (Claude or Gemini) for managing an Obsidian vault. Supports Telegram and Slack (Socket Mode).
+// (Claude or Gemini) for managing an Obsidian vault. Supports Telegram, Slack (Socket Mode) and Discord.
 package main
 
 import (
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+	_ "time/tzdata" // TIMEZONE works without the system's zoneinfo
 
 	"github.com/gpng/obsidian-pa/src/executor"
+	"github.com/gpng/obsidian-pa/src/journal"
+	"github.com/gpng/obsidian-pa/src/prefs"
+	"github.com/gpng/obsidian-pa/src/prompts"
+	"github.com/gpng/obsidian-pa/src/sessions"
+	"github.com/gpng/obsidian-pa/src/usage"
 )
 
 // DefaultVaultPath is the default path to the Obsidian vault in the container
@@ -20,11 +28,32 @@ const DefaultClaudeModel = "claude-haiku-4-5"
 // DefaultGeminiModel is the default Gemini model to use (auto = let Gemini CLI choose)
 const DefaultGeminiModel = "auto"
 
+// DefaultClaudeModels are the Claude models selectable with /model (alias=model)
+const DefaultClaudeModels = "haiku=claude-haiku-4-5,sonnet=claude-sonnet-4-5,opus=claude-opus-4-1"
+
+// DefaultGeminiModels are the Gemini models selectable with /model (alias=model)
+const DefaultGeminiModels = "auto,flash=gemini-2.5-flash,pro=gemini-2.5-pro"
+
+// DefaultDataDir is the default directory for bot state (usage ledger, etc.)
+const DefaultDataDir = "/config/obsidian-pa"
+
+// DefaultExecutorTimeout is the default maximum duration of a single AI run
+const DefaultExecutorTimeout = 10 * time.Minute
+
+// DefaultCommandsDir is the vault folder of prompt command notes
+const DefaultCommandsDir = "PA/Commands"
+
+// DefaultSessionSummaryDir is the vault folder for summaries of expired sessions
+const DefaultSessionSummaryDir = "PA/Sessions"
+
+// DefaultGeminiHomeLinks are linked into Gemini's HOME so an existing OAuth login keeps working
+const DefaultGeminiHomeLinks = ".gemini"
+
 func main() {
-	// Determine which AI executor to use (default: claude)
-	executorType := strings.ToLower(os.Getenv("AI_EXECUTOR"))
-	if executorType == "" {
-		executorType = "claude" // Default to Claude for backward compatibility
+	// Determine which AI executors to use, in fallback order (default: claude)
+	executorList := strings.ToLower(os.Getenv("AI_EXECUTOR"))
+	if executorList == "" {
+		executorList = "claude" // Default to Claude for backward compatibility
 	}
 
 	// Load vault path (shared across executors)
@@ -33,39 +62,168 @@ func main() {
 		vaultPath = DefaultVaultPath
 	}
 
-	// Create the appropriate executor
-	var exec executor.Executor
-	switch executorType {
-	case "gemini":
-		apiKey := os.Getenv("GEMINI_API_KEY") // Optional - can use OAuth
-		model := os.Getenv("GEMINI_MODEL")
-		if model == "" {
-			model = DefaultGeminiModel
+	// Load bot state directory (persistent, outside the vault)
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = DefaultDataDir
+	}
+
+	// Load per-request timeout (shared across executors, "0" disables it)
+	timeout := DefaultExecutorTimeout
+	if timeoutStr := os.Getenv("EXECUTOR_TIMEOUT"); timeoutStr != "" {
+		var err error
+		timeout, err = time.ParseDuration(timeoutStr)
+		if err != nil {
+			log.Fatalf("Invalid EXECUTOR_TIMEOUT: %v", err)
 		}
-		exec = executor.NewGemini(&executor.Config{
-			APIKey:    apiKey,
+	}
+
+	// Load declarative executors for additional agent CLIs (optional)
+	templatesPath := os.Getenv("EXECUTORS_FILE")
+	if templatesPath == "" {
+		templatesPath = filepath.Join(dataDir, "executors.json")
+	}
+	templates, err := executor.LoadTemplates(templatesPath)
+	if err != nil {
+		log.Fatalf("Invalid EXECUTORS_FILE: %v", err)
+	}
+
+	// Load executor process sandboxing: env allowlist and optional resource limits
+	executorEnv := append(append([]string{}, executor.DefaultEnv...), parseList(os.Getenv("EXECUTOR_ENV"))...)
+	limits := executor.Limits{
+		CPUTime:   parseDuration("EXECUTOR_CPU_LIMIT"),
+		MemoryMB:  parseLimit("EXECUTOR_MEMORY_LIMIT_MB"),
+		OpenFiles: parseLimit("EXECUTOR_OPEN_FILES_LIMIT"),
+	}
+
+	// Register the executors; later ones are fallbacks for transient failures
+	registry := executor.NewRegistry()
+	for _, executorType := range strings.Split(executorList, ",") {
+		executorType = strings.TrimSpace(executorType)
+		registerExecutor(registry, executorType, templates, &executor.Config{
 			VaultPath: vaultPath,
-			Model:     model,
+			Timeout:   timeout,
+			StateDir:  dataDir,
+			Env:       executorEnv,
+			HomeDir:   filepath.Join(dataDir, "home", executorType),
+			Limits:    limits,
 		})
-		log.Printf("Using Gemini executor with model: %s", model)
+	}
+	if len(registry.Executors()) > 1 {
+		log.Printf("Executor fallback order: %s", registry.Chain("").Name())
+	}
 
-	case "claude":
-		fallthrough
+	// Open usage ledger with optional budget caps
+	budget := usage.Budget{
+		DailyUSD:   parseBudget("DAILY_BUDGET_USD"),
+		MonthlyUSD: parseBudget("MONTHLY_BUDGET_USD"),
+	}
+	ledger, err := usage.NewLedger(filepath.Join(dataDir, "usage"), budget)
+	if err != nil {
+		log.Fatalf("Failed to open usage ledger: %v", err)
+	}
+	if budget.DailyUSD > 0 || budget.MonthlyUSD > 0 {
+		for _, e := range registry.Executors() {
+			if !executor.ReportsCost(e) {
+				log.Printf("Warning: %s doesn't report cost, so its runs don't count towards the budget caps", e.Name())
+			}
+		}
+	}
+
+	// Load per-chat executor and model selection
+	chatPrefs, err := prefs.Open(filepath.Join(dataDir, "chats.json"))
+	if err != nil {
+		log.Fatalf("Failed to open chat preferences: %v", err)
+	}
+
+	// Load resumable sessions, so conversations continue across restarts
+	sessionStore, err := sessions.Open(filepath.Join(dataDir, "sessions.json"))
+	if err != nil {
+		log.Fatalf("Failed to open session store: %v", err)
+	}
+	log.Printf("Restored %d session(s) from %s", sessionStore.Len(), filepath.Join(dataDir, "sessions.json"))
+
+	// Load default permission mode (read-only unless configured)
+	mode := executor.ModeReadOnly
+	if modeStr := os.Getenv("PERMISSION_MODE"); modeStr != "" {
+		var ok bool
+		if mode, ok = executor.ParsePermissionMode(modeStr); !ok {
+			log.Fatalf("Invalid PERMISSION_MODE: %q (expected read, append or full)", modeStr)
+		}
+	}
+
+	app := &App{
+		Registry: registry,
+		Prefs:    chatPrefs,
+		Mode:     mode,
+		Ledger:   ledger,
+		Sessions: sessionStore,
+
+		// Optional: the allowed Telegram, Slack and Discord users are one person sharing a session
+		SharedSession: os.Getenv("SHARED_SESSION") == "true",
+
+		// Optional: roll idle sessions over into summary notes in the vault
+		Expiry: SessionExpiry{
+			IdleTimeout: parseDuration("SESSION_IDLE_TIMEOUT"),
+			Rollover:    os.Getenv("SESSION_ROLLOVER"),
+			SummaryDir:  orDefault(os.Getenv("SESSION_SUMMARY_DIR"), DefaultSessionSummaryDir),
+			VaultPath:   vaultPath,
+		},
+	}
+	// Queue AI runs on a bounded pool of workers, one at a time per session
+	maxRuns := parseLimit("MAX_CONCURRENT_RUNS")
+	if maxRuns == 0 {
+		maxRuns = DefaultMaxConcurrentRuns
+	}
+	app.jobs = newJobQueue(maxRuns)
+
+	// Optional: combine messages sent in quick succession into one prompt
+	app.batches = newBatcher(parseDuration("MESSAGE_DEBOUNCE"))
+
+	// Send long responses as a Markdown file with a summary ("off" = only when asked for)
+	app.FileThreshold = DefaultFileThreshold
+	if os.Getenv("FILE_RESPONSE_THRESHOLD") == "off" {
+		app.FileThreshold = 0
+	} else if threshold := parseLimit("FILE_RESPONSE_THRESHOLD"); threshold > 0 {
+		app.FileThreshold = threshold
+	}
+
+	// Add the date, timezone and message details around prompts, from an editable template
+	switch envelope := os.Getenv("PROMPT_ENVELOPE"); envelope {
+	case "off":
+	case "":
+		app.Envelope = &PromptEnvelope{}
 	default:
-		apiKey := os.Getenv("ANTHROPIC_API_KEY")
-		if apiKey == "" {
-			log.Fatal("ANTHROPIC_API_KEY environment variable is required for Claude executor")
+		if !filepath.IsAbs(envelope) {
+			envelope = filepath.Join(vaultPath, envelope)
+		}
+		app.Envelope = &PromptEnvelope{Path: envelope}
+	}
+	// Dates in prompts and session rollovers use TIMEZONE, or the container's TZ
+	if tz := os.Getenv("TIMEZONE"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			log.Fatalf("Invalid TIMEZONE: %v", err)
 		}
-		model := os.Getenv("CLAUDE_MODEL")
-		if model == "" {
-			model = DefaultClaudeModel
+		app.Expiry.Location = loc
+		if app.Envelope != nil {
+			app.Envelope.Location = loc
+		}
+	}
+
+	// Prompt commands from notes in the vault, picked up as they change
+	app.CommandsDir = orDefault(os.Getenv("COMMANDS_DIR"), DefaultCommandsDir)
+	app.Commands = prompts.New(filepath.Join(vaultPath, app.CommandsDir))
+
+	// Optional: append every exchange to a dated transcript in the vault
+	if journalDir := os.Getenv("JOURNAL_DIR"); journalDir != "" {
+		app.Journal = journal.New(filepath.Join(vaultPath, journalDir))
+		log.Printf("Journaling conversations to %s", journalDir)
+	}
+	if app.Expiry.Rollover != "" {
+		if _, err := time.Parse("15:04", app.Expiry.Rollover); err != nil {
+			log.Fatalf("Invalid SESSION_ROLLOVER: %q (expected a local time such as 04:00)", app.Expiry.Rollover)
 		}
-		exec = executor.NewClaude(&executor.Config{
-			APIKey:    apiKey,
-			VaultPath: vaultPath,
-			Model:     model,
-		})
-		log.Printf("Using Claude executor with model: %s", model)
 	}
 
 	// Load Telegram configuration (optional)
@@ -100,22 +258,170 @@ func main() {
 		}
 	}
 
+	// Load Discord configuration (optional)
+	discordToken := os.Getenv("DISCORD_TOKEN")
+	discordUserIDs := parseList(os.Getenv("ALLOWED_DISCORD_USER_IDS"))
+	discordEnabled := discordToken != "" && len(discordUserIDs) > 0
+
+	var discordConfig *DiscordConfig
+	if discordEnabled {
+		discordConfig = &DiscordConfig{
+			Token:          discordToken,
+			AllowedUserIDs: discordUserIDs,
+			ChannelID:      os.Getenv("DISCORD_CHANNEL_ID"),
+			APIURL:         os.Getenv("DISCORD_API_URL"),
+		}
+	}
+
 	// Ensure at least one platform is enabled
-	if !telegramEnabled && !slackEnabled {
-		log.Fatal("At least one platform must be configured. Set TELEGRAM_TOKEN + ALLOWED_TELEGRAM_USER_ID for Telegram, or SLACK_APP_TOKEN + SLACK_BOT_TOKEN + ALLOWED_SLACK_USER_ID for Slack.")
+	if !telegramEnabled && !slackEnabled && !discordEnabled {
+		log.Fatal("At least one platform must be configured. Set TELEGRAM_TOKEN + ALLOWED_TELEGRAM_USER_ID for Telegram, SLACK_APP_TOKEN + SLACK_BOT_TOKEN + ALLOWED_SLACK_USER_ID for Slack, or DISCORD_TOKEN + ALLOWED_DISCORD_USER_IDS for Discord.")
 	}
 
 	// Start enabled platforms
 	if telegramEnabled {
 		log.Println("Starting Telegram bot...")
-		go runTelegramBot(telegramConfig, exec)
+		go runTelegramBot(telegramConfig, app)
 	}
 
 	if slackEnabled {
 		log.Println("Starting Slack bot (Socket Mode)...")
-		go runSlackBot(slackConfig, exec)
+		go runSlackBot(slackConfig, app)
+	}
+
+	if discordEnabled {
+		log.Println("Starting Discord bot...")
+		go runDiscordBot(discordConfig, app)
+	}
+
+	// Expire idle sessions in the background
+	if app.Expiry.Enabled() {
+		log.Printf("Session expiry: idle timeout %s, daily rollover %s, summaries in %s",
+			app.Expiry.IdleTimeout, orDefault(app.Expiry.Rollover, "off"), app.Expiry.SummaryDir)
+		go app.runExpiry()
 	}
 
 	// Block forever
 	select {}
 }
+
+// registerExecutor creates an executor by type from its environment configuration
+// and adds it to the registry with its selectable models. Types other than the built-in
+// ones are looked up in templates. base holds the settings shared by all executors.
+func registerExecutor(registry *executor.Registry, executorType string, templates []executor.TemplateSpec, base *executor.Config) {
+	config := *base
+
+	switch executorType {
+	case "gemini":
+		config.APIKey = os.Getenv("GEMINI_API_KEY") // Optional - can use OAuth
+		config.Model = os.Getenv("GEMINI_MODEL")
+		if config.Model == "" {
+			config.Model = DefaultGeminiModel
+		}
+		config.HomeLinks = parseList(orDefault(os.Getenv("GEMINI_HOME_LINKS"), DefaultGeminiHomeLinks))
+		log.Printf("Using Gemini executor with model: %s", config.Model)
+		registry.Register(executor.NewGemini(&config), config.Model, loadModels("GEMINI_MODELS", DefaultGeminiModels, config.Model))
+
+	case "claude":
+		config.APIKey = os.Getenv("ANTHROPIC_API_KEY")
+		if config.APIKey == "" {
+			log.Fatal("ANTHROPIC_API_KEY environment variable is required for Claude executor")
+		}
+		config.Model = os.Getenv("CLAUDE_MODEL")
+		if config.Model == "" {
+			config.Model = DefaultClaudeModel
+		}
+		log.Printf("Using Claude executor with model: %s", config.Model)
+		registry.Register(executor.NewClaude(&config), config.Model, loadModels("CLAUDE_MODELS", DefaultClaudeModels, config.Model))
+
+	case "openai":
+		config.BaseURL = os.Getenv("OPENAI_BASE_URL")
+		config.Model = os.Getenv("OPENAI_MODEL")
+		if config.BaseURL == "" || config.Model == "" {
+			log.Fatal("OPENAI_BASE_URL and OPENAI_MODEL environment variables are required for OpenAI executor")
+		}
+		config.APIKey = os.Getenv("OPENAI_API_KEY") // Optional - local servers usually need none
+		log.Printf("Using OpenAI-compatible executor at %s with model: %s", config.BaseURL, config.Model)
+		registry.Register(executor.NewOpenAI(&config), config.Model, loadModels("OPENAI_MODELS", "", config.Model))
+
+	default:
+		for _, spec := range templates {
+			if strings.EqualFold(spec.Name, executorType) {
+				config.Model = spec.Model
+				config.HomeLinks = spec.HomeLinks
+				log.Printf("Using %s template executor (%s) with model: %s", spec.Name, spec.Binary, config.Model)
+				registry.Register(executor.NewTemplate(&config, spec), config.Model, loadModels("", spec.Models, config.Model))
+				return
+			}
+		}
+		log.Fatalf("Unknown executor in AI_EXECUTOR: %q (expected claude, gemini, openai or a template from EXECUTORS_FILE)", executorType)
+	}
+}
+
+// loadModels reads the selectable models for an executor, making sure the default model is included
+func loadModels(key, fallback, defaultModel string) []executor.Model {
+	list := os.Getenv(key)
+	if list == "" {
+		list = fallback
+	}
+	models := executor.ParseModels(list)
+	if defaultModel == "" {
+		return models
+	}
+	for _, m := range models {
+		if m.ID == defaultModel {
+			return models
+		}
+	}
+	return append([]executor.Model{{Alias: defaultModel, ID: defaultModel}}, models...)
+}
+
+// parseList splits a comma-separated list, dropping empty entries
+func parseList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseDuration reads an optional duration from the environment (0 = unset)
+func parseDuration(key string) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		log.Fatalf("Invalid %s: %q", key, value)
+	}
+	return duration
+}
+
+// parseLimit reads an optional non-negative integer limit from the environment (0 = unset)
+func parseLimit(key string) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		log.Fatalf("Invalid %s: %q", key, value)
+	}
+	return limit
+}
+
+// parseBudget reads an optional USD budget cap from the environment (0 = no cap)
+func parseBudget(key string) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	budget, err := strconv.ParseFloat(value, 64)
+	if err != nil || budget < 0 {
+		log.Fatalf("Invalid %s: %q", key, value)
+	}
+	return budget
+}
@@ -0,0 +1,75 @@
+// Package main provides the adapter interface between messaging platforms and the router.
+package main
+
+import "time"
+
+// Platform is a messaging platform adapter. It only receives and delivers messages;
+// the router does everything else: auth, commands, sessions and the run queue.
+type Platform interface {
+	// Name identifies the platform in session keys, preferences and logs, e.g. "telegram"
+	Name() string
+
+	// AllowedUsers are the IDs of the users the bot answers
+	AllowedUsers() []string
+
+	// Home is the direct chat with the allowed user, where handoffs from other platforms
+	// are posted (false = none)
+	Home() (Conversation, bool)
+
+	// Receive calls handle with every incoming message until the connection closes.
+	// handle must return quickly; it must not wait for runs.
+	Receive(handle func(Message)) error
+
+	// Send posts plain text and returns the message's ID, for Edit and Delete
+	Send(conv Conversation, text string) (string, error)
+
+	// SendMarkdown posts standard Markdown in the platform's format, split into as
+	// many messages as it needs
+	SendMarkdown(conv Conversation, md string) error
+
+	// Edit replaces the text of a message sent with Send
+	Edit(conv Conversation, id, text string) error
+
+	// Delete removes a message sent with Send
+	Delete(conv Conversation, id string) error
+
+	// Upload posts a file with a caption in standard Markdown
+	Upload(conv Conversation, name string, content []byte, caption string) error
+}
+
+// Conversation identifies a chat, or a thread within it
+type Conversation struct {
+	Chat   string // Chat or channel ID
+	Thread string // Thread or forum topic (empty = the chat itself)
+	Direct bool   // The direct chat with the allowed user, which SHARED_SESSION links across platforms
+}
+
+// Message is a message received by a platform
+type Message struct {
+	Conversation Conversation
+	UserID       string    // Sender, checked against the platform's allowed users
+	Sender       string    // Display name of the sender
+	Text         string    // Message text
+	Sent         time.Time // When the message was sent
+	ReplyTo      string    // Text of the message it replies to
+
+	// ThreadParent looks up the message a thread started from, for the first run in
+	// the thread (nil = not available)
+	ThreadParent func() string
+
+	// BareCommands accepts commands without the slash, e.g. "status" (Slack intercepts slash commands)
+	BareCommands bool
+}
+
+// chatKey identifies the conversation's chat for per-chat preferences, e.g. "telegram:123"
+func (c Conversation) chatKey(platform string) string {
+	return platform + ":" + c.Chat
+}
+
+// key identifies the conversation in the session store, e.g. "slack:D123:1712345678.123456"
+func (c Conversation) key(platform string) string {
+	if c.Thread != "" {
+		return c.chatKey(platform) + ":" + c.Thread
+	}
+	return c.chatKey(platform)
+}
@@ -0,0 +1,51 @@
+// Package prefs persists per-chat settings such as the selected executor and model.
+package prefs
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gpng/obsidian-pa/src/jsonfile"
+)
+
+// Chat holds the settings for one chat (empty fields use the configured defaults)
+type Chat struct {
+	Executor string `json:"executor,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Mode     string `json:"mode,omitempty"` // Permission mode: read, append or full
+}
+
+// Store is a file-backed map of chat keys (e.g. "telegram:12345") to settings
+type Store struct {
+	path string
+
+	mu    sync.Mutex
+	chats map[string]Chat
+}
+
+// Open loads the store from path, starting empty if the file doesn't exist
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, chats: make(map[string]Chat)}
+	if err := jsonfile.Load(path, &s.chats); err != nil {
+		return nil, fmt.Errorf("load chat preferences: %w", err)
+	}
+	return s, nil
+}
+
+// Get returns the settings for a chat
+func (s *Store) Get(key string) Chat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chats[key]
+}
+
+// Update modifies the settings for a chat and saves the store
+func (s *Store) Update(key string, fn func(*Chat)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat := s.chats[key]
+	fn(&chat)
+	s.chats[key] = chat
+	return jsonfile.Save(s.path, s.chats)
+}
@@ -0,0 +1,96 @@
+// Package main provides live progress reporting shared by the messaging platforms.
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gpng/obsidian-pa/src/executor"
+)
+
+// progressInterval is the minimum time between progress message edits (platform rate limits)
+const progressInterval = 2 * time.Second
+
+// progressReporter turns executor events into throttled edits of the processing indicator
+type progressReporter struct {
+	update func(text string)
+
+	mu       sync.Mutex
+	last     string
+	lastSent time.Time
+}
+
+// newProgressReporter creates a reporter that calls update with each new status line
+func newProgressReporter(update func(text string)) *progressReporter {
+	return &progressReporter{update: update}
+}
+
+// Handle is an executor.EventHandler that edits the indicator when the status changes
+func (p *progressReporter) Handle(event executor.Event) {
+	text := describeEvent(event)
+	if text == "" {
+		return
+	}
+
+	p.mu.Lock()
+	// Fallbacks are always shown; other updates are throttled
+	throttled := event.Type != executor.EventFallback && time.Since(p.lastSent) < progressInterval
+	if text == p.last || throttled {
+		p.mu.Unlock()
+		return
+	}
+	p.last = text
+	p.lastSent = time.Now()
+	p.mu.Unlock()
+
+	p.update(text)
+}
+
+// describeEvent returns the status line for an executor event, or "" to keep the current one
+func describeEvent(event executor.Event) string {
+	switch event.Type {
+	case executor.EventInit:
+		if event.Model != "" {
+			return fmt.Sprintf("🧠 Thinking (%s)…", event.Model)
+		}
+		return "🧠 Thinking…"
+	case executor.EventText:
+		return "💬 Writing…"
+	case executor.EventToolUse:
+		return describeTool(event)
+	case executor.EventFallback:
+		return fmt.Sprintf("🔁 Switching to %s…", event.Text)
+	}
+	return ""
+}
+
+// describeTool returns the status line for a tool call
+func describeTool(event executor.Event) string {
+	target := event.Path
+	switch event.Action {
+	case executor.ActionRead:
+		return fmt.Sprintf("📖 Reading %s…", orDefault(target, "notes"))
+	case executor.ActionWrite:
+		return fmt.Sprintf("📝 Writing %s…", orDefault(target, "a note"))
+	case executor.ActionEdit:
+		return fmt.Sprintf("✏️ Editing %s…", orDefault(target, "a note"))
+	case executor.ActionSearch:
+		return fmt.Sprintf("🔍 Searching %s…", orDefault(target, "the vault"))
+	case executor.ActionList:
+		return fmt.Sprintf("📂 Listing %s…", orDefault(target, "the vault"))
+	case executor.ActionShell:
+		return "💻 Running a command…"
+	case executor.ActionWeb:
+		return "🌐 Searching the web…"
+	}
+	return fmt.Sprintf("🔧 Using %s…", event.Tool)
+}
+
+// orDefault returns s, or fallback if s is empty
+func orDefault(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
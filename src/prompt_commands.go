@@ -0,0 +1,110 @@
+// Package main provides prompt commands, such as /start, defined by notes in the vault.
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gpng/obsidian-pa/src/executor"
+	"github.com/gpng/obsidian-pa/src/prompts"
+)
+
+// command returns a prompt command from the vault, or the built-in /start if the vault doesn't override it
+func (a *App) command(name string) (prompts.Command, bool) {
+	if cmd, ok := a.Commands.Lookup(name); ok {
+		return cmd, true
+	}
+	if name == "start" {
+		return prompts.Command{
+			Name:        "start",
+			Description: "Read AGENT.md and start daily review",
+			Title:       "Daily review",
+			NewSession:  true,
+			Body:        a.startPrompt(),
+		}, true
+	}
+	return prompts.Command{}, false
+}
+
+// commandPrompt renders a prompt command, prefixed so prepareRun applies its model and mode.
+// Anyone who can edit the vault can write a command, so its mode can lower the chat's mode
+// but never raise it, and its body can't carry a mode prefix of its own.
+func (a *App) commandPrompt(chatKey string, cmd prompts.Command, arg string) (string, error) {
+	prompt, err := cmd.Render(arg)
+	if err != nil {
+		return "", fmt.Errorf("/%s: %w", cmd.Name, err)
+	}
+	if prompt == "" {
+		return "", fmt.Errorf("/%s: the prompt is empty", cmd.Name)
+	}
+
+	if cmd.Model != "" {
+		_, _, isModel := a.Registry.FindModel(cmd.Model)
+		_, isExecutor := a.Registry.Lookup(cmd.Model)
+		if !isModel && !isExecutor {
+			return "", fmt.Errorf("/%s: unknown model %s", cmd.Name, cmd.Model)
+		}
+		prompt = "@" + cmd.Model + " " + prompt
+	}
+
+	// Always prefix the mode, so a body starting with "/write" is sent as text instead of
+	// being read as the one-off prefix
+	chatMode := a.mode(chatKey)
+	mode := chatMode
+	if cmd.Mode != "" {
+		mode = cmd.Mode.AtMost(chatMode)
+		if mode != cmd.Mode {
+			log.Printf("/%s asks for %s mode, running in the chat's %s mode", cmd.Name, cmd.Mode, mode)
+		}
+	}
+	return modePrefix(mode) + " " + prompt, nil
+}
+
+// commandTitle describes a prompt command's session
+func commandTitle(cmd prompts.Command) string {
+	return sessionTitle(orDefault(cmd.Title, orDefault(cmd.Description, "/"+cmd.Name)))
+}
+
+// commandIndicator is the progress message shown while a prompt command runs
+func commandIndicator(cmd prompts.Command) string {
+	if cmd.Name == "start" {
+		return "🌅 Starting your day... Reading context and reviewing tasks..."
+	}
+	return fmt.Sprintf("⚡ Running /%s...", cmd.Name)
+}
+
+// commandsCommand handles /commands: lists the prompt commands
+func (a *App) commandsCommand() string {
+	list := a.Commands.List()
+	if _, ok := a.Commands.Lookup("start"); !ok {
+		start, _ := a.command("start")
+		list = append([]prompts.Command{start}, list...)
+	}
+
+	var b strings.Builder
+	b.WriteString("⚡ Commands")
+	for _, cmd := range list {
+		if _, builtin := commandTakesArg[cmd.Name]; builtin {
+			continue // Shadowed by a built-in command
+		}
+		fmt.Fprintf(&b, "\n• %s", cmd.Usage())
+		if cmd.Description != "" {
+			fmt.Fprintf(&b, " - %s", cmd.Description)
+		}
+	}
+	if a.Commands != nil {
+		fmt.Fprintf(&b, "\n\nAdd your own as notes in %s.", a.CommandsDir)
+	}
+	return b.String()
+}
+
+// modePrefix returns the one-off prefix that runs a message in a permission mode
+func modePrefix(mode executor.PermissionMode) string {
+	for prefix, prefixMode := range modePrefixes {
+		if prefixMode == mode && strings.HasPrefix(prefix, "/") {
+			return prefix
+		}
+	}
+	return ""
+}
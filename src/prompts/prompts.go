@@ -0,0 +1,262 @@
+// Package prompts loads custom chat commands from notes in a vault folder.
+//
+// Each note defines one command: its frontmatter holds the name, description,
+// arguments, model and permission mode, and its body is the prompt template.
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/gpng/obsidian-pa/src/executor"
+)
+
+// Command is a prompt shortcut such as /weekly
+type Command struct {
+	Name        string
+	Description string
+	Title       string                  // Title of the session it starts ("" = the description)
+	Args        []string                // Argument names; the last one takes the rest of the line
+	Model       string                  // Model alias or executor to run on ("" = the chat's)
+	Mode        executor.PermissionMode // Permission mode, at most the chat's ("" = the chat's)
+	NewSession  bool                    // Start a fresh session before running
+	Body        string                  // Prompt template
+	Path        string                  // Note the command was loaded from ("" = built in)
+}
+
+// Usage describes how to call the command, e.g. "/weekly <week> <focus>"
+func (c Command) Usage() string {
+	usage := "/" + c.Name
+	for _, arg := range c.Args {
+		usage += " <" + arg + ">"
+	}
+	return usage
+}
+
+// Render fills the prompt template with the command's arguments. The template can use
+// each argument by name, e.g. {{.week}}, and {{.args}} for the whole argument line.
+// Arguments are appended to a template that uses none.
+func (c Command) Render(arg string) (string, error) {
+	arg = strings.TrimSpace(arg)
+	if !strings.Contains(c.Body, "{{") {
+		if arg == "" {
+			return c.Body, nil
+		}
+		return c.Body + "\n\n" + arg, nil
+	}
+
+	tmpl, err := template.New(c.Name).Option("missingkey=zero").Parse(c.Body)
+	if err != nil {
+		return "", err
+	}
+
+	data := map[string]string{"args": arg}
+	rest := arg
+	for i, name := range c.Args {
+		if i == len(c.Args)-1 {
+			data[name] = rest
+			break
+		}
+		data[name], rest, _ = strings.Cut(rest, " ")
+		rest = strings.TrimSpace(rest)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// Library is the commands in a vault folder. Notes are re-read whenever they change,
+// so commands can be added and edited without a restart.
+type Library struct {
+	dir string
+
+	mu    sync.Mutex
+	notes map[string]note // Parsed notes by path
+}
+
+// note is a parsed command note and the modification time it was parsed at
+type note struct {
+	modified time.Time
+	command  Command
+	err      error
+}
+
+// New creates a library of the commands in dir
+func New(dir string) *Library {
+	return &Library{dir: dir, notes: make(map[string]note)}
+}
+
+// Lookup returns the command with the given name
+func (l *Library) Lookup(name string) (Command, bool) {
+	for _, cmd := range l.List() {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return Command{}, false
+}
+
+// List returns the commands sorted by name. Notes that fail to parse are logged and skipped.
+func (l *Library) List() []Command {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to read commands folder: %v", err)
+		}
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var list []Command
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".md") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(l.dir, entry.Name())
+		seen[path] = true
+		cached, ok := l.notes[path]
+		if !ok || !cached.modified.Equal(info.ModTime()) {
+			cached = note{modified: info.ModTime()}
+			cached.command, cached.err = load(path)
+			if cached.err != nil {
+				log.Printf("Skipping command note %s: %v", path, cached.err)
+			}
+			l.notes[path] = cached
+		}
+		if cached.err == nil {
+			list = append(list, cached.command)
+		}
+	}
+
+	for path := range l.notes {
+		if !seen[path] {
+			delete(l.notes, path)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// load parses a command note
+func load(path string) (Command, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Command{}, err
+	}
+
+	fields, body := parseFrontmatter(string(data))
+	cmd := Command{
+		Name:        strings.ToLower(fields["name"]),
+		Description: fields["description"],
+		Title:       fields["title"],
+		Args:        parseList(fields["args"]),
+		Model:       fields["model"],
+		NewSession:  fields["session"] == "new",
+		Body:        strings.TrimSpace(body),
+		Path:        path,
+	}
+	if cmd.Name == "" {
+		cmd.Name = strings.ToLower(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	}
+	if strings.ContainsAny(cmd.Name, " \t/@") {
+		return Command{}, fmt.Errorf("invalid command name %q", cmd.Name)
+	}
+	if cmd.Body == "" {
+		return Command{}, errors.New("empty prompt")
+	}
+	if mode := fields["mode"]; mode != "" {
+		var ok bool
+		if cmd.Mode, ok = executor.ParsePermissionMode(mode); !ok {
+			return Command{}, fmt.Errorf("invalid mode %q (expected read, append or full)", mode)
+		}
+	}
+	if _, err := template.New(cmd.Name).Parse(cmd.Body); err != nil {
+		return Command{}, err
+	}
+	return cmd, nil
+}
+
+// parseFrontmatter splits a note into its frontmatter fields and body. Only the
+// simple "key: value" lines and "- item" lists that commands need are understood.
+func parseFrontmatter(text string) (map[string]string, string) {
+	fields := make(map[string]string)
+	text = strings.TrimPrefix(text, "\ufeff") // Byte order mark
+	if !strings.HasPrefix(text, "---\n") && !strings.HasPrefix(text, "---\r\n") {
+		return fields, text
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	var key string
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "---" {
+			return fields, strings.Join(lines[i+1:], "")
+		}
+
+		// Block list item of the previous key
+		if item, ok := strings.CutPrefix(line, "- "); ok && key != "" {
+			if fields[key] != "" {
+				fields[key] += ", "
+			}
+			fields[key] += unquote(item)
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(k))
+		fields[key] = unquote(v)
+	}
+
+	// Unterminated frontmatter - treat the whole note as the body
+	return map[string]string{}, text
+}
+
+// parseList parses "a, b", "[a, b]" or a block list joined by parseFrontmatter
+func parseList(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = unquote(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// unquote trims spaces and surrounding quotes from a frontmatter value
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,224 @@
+// Package main provides the job queue that runs AI requests for all messaging platforms.
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxConcurrentRuns is how many AI runs may execute at once by default
+const DefaultMaxConcurrentRuns = 2
+
+// job is a queued AI run for a conversation's session
+type job struct {
+	key      string // Session key; jobs with the same key run one at a time, in order
+	platform string
+	label    string // Short description for /queue
+	queued   time.Time
+	started  time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	run    func(ctx context.Context)
+}
+
+// jobQueue runs jobs on a bounded pool of workers, in submission order,
+// with at most one running job per session
+type jobQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	workers int             // Number of workers
+	pending []*job          // Waiting jobs, oldest first
+	running map[string]*job // Running job per session key
+}
+
+// newJobQueue starts a queue with the given number of workers
+func newJobQueue(workers int) *jobQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	q := &jobQueue{workers: workers, running: make(map[string]*job)}
+	q.cond = sync.NewCond(&q.mu)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// submit queues a job for a session. Returns its position in the queue,
+// or 0 if it starts right away.
+func (q *jobQueue) submit(key, platform, label string, run func(ctx context.Context)) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		key:      key,
+		platform: platform,
+		label:    label,
+		queued:   time.Now(),
+		ctx:      ctx,
+		cancel:   cancel,
+		run:      run,
+	}
+
+	// Free workers are about to start the oldest waiting job of each session with nothing running
+	free := q.workers - len(q.running)
+	starting := make(map[string]bool)
+	for _, p := range q.pending {
+		if q.running[p.key] == nil {
+			starting[p.key] = true
+		}
+	}
+	startsNow := q.running[key] == nil && !starting[key] && free > len(starting)
+
+	q.pending = append(q.pending, j)
+	q.cond.Signal()
+	if startsNow {
+		return 0
+	}
+	return len(q.pending) - min(free, len(starting))
+}
+
+// cancel aborts a session's running job, killing the AI CLI process group, and drops
+// its waiting jobs. Returns whether a job was running and how many were dropped.
+func (q *jobQueue) cancel(key string) (running bool, dropped int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if j := q.running[key]; j != nil {
+		j.cancel()
+		running = true
+	}
+
+	pending := q.pending[:0]
+	for _, j := range q.pending {
+		if j.key == key {
+			j.cancel()
+			dropped++
+			continue
+		}
+		pending = append(pending, j)
+	}
+	q.pending = pending
+	return running, dropped
+}
+
+// busy reports whether a session has a job running or waiting
+func (q *jobQueue) busy(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.running[key] != nil {
+		return true
+	}
+	for _, j := range q.pending {
+		if j.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// status describes the running and waiting jobs for /queue, marking those of a session
+func (q *jobQueue) status(key string) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.running) == 0 && len(q.pending) == 0 {
+		return "✅ Nothing running or queued."
+	}
+
+	now := time.Now()
+	describe := func(j *job) string {
+		text := fmt.Sprintf("%s: %s", platformName(j.platform), j.label)
+		if j.key == key {
+			text += " (here)"
+		}
+		return text
+	}
+
+	var b strings.Builder
+	if len(q.running) > 0 {
+		running := make([]*job, 0, len(q.running))
+		for _, j := range q.running {
+			running = append(running, j)
+		}
+		sort.Slice(running, func(i, k int) bool {
+			return running[i].started.Before(running[k].started)
+		})
+		fmt.Fprintf(&b, "⚙️ Running (%d):\n", len(running))
+		for _, j := range running {
+			fmt.Fprintf(&b, "• %s · %s\n", describe(j), formatAge(now.Sub(j.started)))
+		}
+	}
+	if len(q.pending) > 0 {
+		fmt.Fprintf(&b, "⏳ Queued (%d):\n", len(q.pending))
+		for i, j := range q.pending {
+			fmt.Fprintf(&b, "%d. %s · waiting %s\n", i+1, describe(j), formatAge(now.Sub(j.queued)))
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// work runs jobs until the program exits
+func (q *jobQueue) work() {
+	for {
+		j := q.next()
+		j.run(j.ctx)
+		q.finish(j)
+	}
+}
+
+// next waits for the oldest job whose session has nothing running, and marks it running
+func (q *jobQueue) next() *job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		for i, j := range q.pending {
+			if q.running[j.key] == nil {
+				q.pending = append(q.pending[:i:i], q.pending[i+1:]...)
+				j.started = time.Now()
+				q.running[j.key] = j
+				return j
+			}
+		}
+		q.cond.Wait()
+	}
+}
+
+// finish releases a finished job's session so its next job can start
+func (q *jobQueue) finish(j *job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	j.cancel()
+	delete(q.running, j.key)
+	q.cond.Broadcast()
+}
+
+// queuedMessage tells the user a job is waiting, or returns "" if it started right away
+func queuedMessage(position int) string {
+	if position == 0 {
+		return ""
+	}
+	return fmt.Sprintf("⏳ Queued at position %d. Send /queue to see what's running, or /cancel to drop it.", position)
+}
+
+// cancelMessage describes the result of cancelling a session's jobs for /cancel
+func cancelMessage(running bool, dropped int) string {
+	switch {
+	case running && dropped > 0:
+		return fmt.Sprintf("🛑 Cancelling the current run and %d queued message(s)...", dropped)
+	case running:
+		return "🛑 Cancelling the current run..."
+	case dropped > 0:
+		return fmt.Sprintf("🛑 Dropped %d queued message(s).", dropped)
+	}
+	return "ℹ️ Nothing is running."
+}
@@ -0,0 +1,250 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunk splits Markdown into parts no longer than limit as measured by length, which
+// must add up over concatenation (like utf8.RuneCountInString or UTF16Length).
+//
+// Parts break at blank lines where possible and otherwise between lines; only a line
+// that is too long on its own is cut, at a space if it has one, and never inside a
+// character. A code block that is split is closed at the end of the part and reopened
+// at the start of the next, so every part renders on its own. When there is more than
+// one part, each ends with its number, e.g. "1/3".
+func Chunk(md string, limit int, length func(string) int) []string {
+	md = strings.TrimRight(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+	if md == "" {
+		return nil
+	}
+	if length(md) <= limit {
+		return []string{md}
+	}
+
+	// Leave room for the part numbers, growing it until the count fits
+	for total := 9; ; total = total*10 + 9 {
+		parts := split(md, limit-length(partLabel(total, total)), length)
+		if len(parts) > total {
+			continue
+		}
+		for i := range parts {
+			parts[i] += partLabel(i+1, len(parts))
+		}
+		return parts
+	}
+}
+
+// Excerpt returns the start of md, at most limit characters long and broken the way
+// Chunk breaks parts, and whether anything was left out
+func Excerpt(md string, limit int) (string, bool) {
+	md = strings.TrimRight(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+	if utf8.RuneCountInString(md) <= limit {
+		return md, false
+	}
+	parts := split(md, limit, utf8.RuneCountInString)
+	if len(parts) == 0 {
+		return "", true
+	}
+	return parts[0], true
+}
+
+// UTF16Length counts the UTF-16 code units of s, which is how Telegram measures text
+func UTF16Length(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// partLabel numbers part i of n
+func partLabel(i, n int) string {
+	return fmt.Sprintf("\n\n_%d/%d_", i, n)
+}
+
+// chunker collects lines into parts
+type chunker struct {
+	limit  int
+	length func(string) int
+	parts  []string
+
+	lines  []string // Lines of the current part
+	size   int      // Length of the current part's lines, each counted with a line break
+	breaks []int    // Blank lines outside code blocks in the current part, where it may end
+	base   int      // Number of leading lines that reopen a code block
+
+	fence  string // Closing fence of the code block open at the end of the current part
+	opener string // Line that opened that code block
+}
+
+// split breaks md into parts of at most limit
+func split(md string, limit int, length func(string) int) []string {
+	c := &chunker{limit: limit, length: length}
+	for _, line := range strings.Split(md, "\n") {
+		c.add(line)
+	}
+	c.emit(len(c.lines))
+	return c.parts
+}
+
+// add appends a line to the current part, starting new parts as needed
+func (c *chunker) add(line string) {
+	fence, opener := c.fenceAfter(line)
+	reserve := 0
+	if fence != "" {
+		reserve = c.length(fence) + 1 // A split here has to close the code block
+	}
+
+	for c.size+c.length(line)+reserve > c.limit && len(c.lines) > c.base {
+		c.flush()
+	}
+
+	// Cut a line that doesn't fit in a part of its own
+	for c.size+c.length(line)+reserve > c.limit {
+		head, tail := cutLine(line, c.limit-c.size-reserve, c.length)
+		c.append(head, fence)
+		c.flush()
+		line = tail
+	}
+
+	c.append(line, fence)
+	c.fence, c.opener = fence, opener
+}
+
+// append adds a line to the current part
+func (c *chunker) append(line, fence string) {
+	if strings.TrimSpace(line) == "" && c.fence == "" && fence == "" {
+		c.breaks = append(c.breaks, len(c.lines))
+	}
+	c.lines = append(c.lines, line)
+	c.size += c.length(line) + 1
+}
+
+// flush ends the current part, at its last blank line if that leaves it at least
+// half full, and carries the remaining lines over to the next part
+func (c *chunker) flush() {
+	for i := len(c.breaks) - 1; i >= 0; i-- {
+		at := c.breaks[i]
+		if c.measure(c.lines[:at]) < c.limit/2 {
+			break
+		}
+		carried := append([]string(nil), c.lines[at+1:]...)
+		var breaks []int
+		for _, b := range c.breaks[i+1:] {
+			breaks = append(breaks, b-at-1)
+		}
+		c.emit(at)
+		c.lines, c.breaks, c.size = carried, breaks, c.measure(carried)
+		return
+	}
+	c.emit(len(c.lines))
+}
+
+// emit turns the first n lines of the current part into a part and drops the rest.
+// If the part ends inside a code block, the block is closed and the next part reopens it.
+func (c *chunker) emit(n int) {
+	lines := c.lines[:n:n]
+	for len(lines) > c.base && strings.TrimSpace(lines[c.base]) == "" {
+		lines = append(lines[:c.base:c.base], lines[c.base+1:]...)
+	}
+	for len(lines) > c.base && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	open := n == len(c.lines) && c.fence != ""
+	if len(lines) > c.base {
+		if open {
+			lines = append(lines, c.fence)
+		}
+		c.parts = append(c.parts, strings.Join(lines, "\n"))
+	}
+
+	c.lines, c.size, c.breaks, c.base = nil, 0, nil, 0
+	if open {
+		c.append(c.opener, c.fence)
+		c.base = 1
+	}
+}
+
+// measure returns the length of lines joined by line breaks
+func (c *chunker) measure(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		n += c.length(line) + 1
+	}
+	return n
+}
+
+// fenceAfter returns the code block open after line, as its closing fence and opening line
+func (c *chunker) fenceAfter(line string) (string, string) {
+	if c.fence == "" {
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			return m[1] + m[2], line
+		}
+		return "", ""
+	}
+	fence := strings.TrimSpace(c.fence)
+	if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+		return "", ""
+	}
+	return c.fence, c.opener
+}
+
+// cutLine splits line so the head is at most n long, preferably after a space in its
+// second half, and never between a character and the marks that join it (as in emoji
+// sequences). The head holds at least one character.
+func cutLine(line string, n int, length func(string) int) (string, string) {
+	cut, space, size := 0, 0, 0
+	for i, r := range line {
+		if i > 0 && !joinsPrevious(line, i) {
+			cut = i
+			if line[i-1] == ' ' {
+				space = i
+			}
+		}
+		if size += length(string(r)); size > n {
+			if space >= cut/2 && space > 0 {
+				cut = space
+			}
+			if cut == 0 {
+				_, cut = utf8.DecodeRuneInString(line)
+			}
+			return line[:cut], line[cut:]
+		}
+	}
+	return line, ""
+}
+
+// joinsPrevious reports whether the character at line[i] belongs with the one before it:
+// a zero-width joiner or the character after one, a variation selector, a skin tone
+// modifier, a combining mark, a tag, or the second half of a flag
+func joinsPrevious(line string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(line[i:])
+	prev, size := utf8.DecodeLastRuneInString(line[:i])
+	if isRegionalIndicator(r) && isRegionalIndicator(prev) {
+		// Flags are pairs of regional indicators, so count the ones before
+		n := 0
+		for j := i; j > 0; j -= size {
+			if prev, size = utf8.DecodeLastRuneInString(line[:j]); !isRegionalIndicator(prev) {
+				break
+			}
+			n++
+		}
+		return n%2 == 1
+	}
+	return r == '\u200d' || prev == '\u200d' ||
+		(r >= 0xfe00 && r <= 0xfe0f) ||
+		(r >= 0x1f3fb && r <= 0x1f3ff) ||
+		(r >= 0x0300 && r <= 0x036f) ||
+		(r >= 0xe0020 && r <= 0xe007f)
+}
+
+// isRegionalIndicator reports whether r is one of the letters that pair up into flags
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
@@ -0,0 +1,169 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// labelPattern matches the part number Chunk appends
+var labelPattern = regexp.MustCompile(`\n\n_(\d+)/(\d+)_$`)
+
+// chunk splits md and checks what holds for every result: parts are valid UTF-8,
+// fit the limit, and are numbered in order when there is more than one
+func chunk(t *testing.T, md string, limit int, length func(string) int) []string {
+	t.Helper()
+	parts := Chunk(md, limit, length)
+	if len(parts) == 0 {
+		t.Fatal("no parts")
+	}
+	for i, part := range parts {
+		if !utf8.ValidString(part) {
+			t.Errorf("part %d is not valid UTF-8", i+1)
+		}
+		if n := length(part); n > limit {
+			t.Errorf("part %d is %d long, over the limit of %d", i+1, n, limit)
+		}
+		if len(parts) == 1 {
+			continue
+		}
+		m := labelPattern.FindStringSubmatch(part)
+		if want := []string{fmt.Sprint(i + 1), fmt.Sprint(len(parts))}; m == nil || m[1] != want[0] || m[2] != want[1] {
+			t.Errorf("part %d is not numbered %s/%s: %q", i+1, want[0], want[1], part)
+		}
+	}
+	return parts
+}
+
+// unlabel strips the part numbers
+func unlabel(parts []string) []string {
+	stripped := make([]string, len(parts))
+	for i, part := range parts {
+		stripped[i] = labelPattern.ReplaceAllString(part, "")
+	}
+	return stripped
+}
+
+// withoutBreaks drops line breaks and blank space between lines, which chunking may move
+func withoutBreaks(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+func TestChunkShortMessageUnchanged(t *testing.T) {
+	md := "**Hello** 👋\n\n```go\nfmt.Println(\"hi\")\n```"
+	parts := chunk(t, md, 100, UTF16Length)
+	if len(parts) != 1 || parts[0] != md {
+		t.Errorf("got %q, want the message unchanged", parts)
+	}
+	if parts := Chunk("", 100, UTF16Length); parts != nil {
+		t.Errorf("got %q for an empty message, want no parts", parts)
+	}
+}
+
+func TestChunkBreaksAtParagraphs(t *testing.T) {
+	var paragraphs []string
+	for i := 0; i < 12; i++ {
+		paragraphs = append(paragraphs, fmt.Sprintf("Paragraph %d has **bold text**\nthat runs over two lines.", i))
+	}
+	md := strings.Join(paragraphs, "\n\n")
+	parts := chunk(t, md, 200, utf8.RuneCountInString)
+	if len(parts) < 2 {
+		t.Fatalf("got %d part, want several", len(parts))
+	}
+	for i, part := range unlabel(parts) {
+		for _, paragraph := range strings.Split(part, "\n\n") {
+			if !strings.HasPrefix(paragraph, "Paragraph ") || !strings.HasSuffix(paragraph, "two lines.") {
+				t.Errorf("part %d splits a paragraph: %q", i+1, paragraph)
+			}
+		}
+	}
+}
+
+func TestChunkEmojiHeavy(t *testing.T) {
+	emoji := []string{"😀", "👍🏽", "👨‍👩‍👧‍👦", "🇸🇬", "❤️", "🏳️‍🌈", "é"}
+	var b strings.Builder
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&b, "Line %d %s %s\n", i, emoji[i%len(emoji)], strings.Repeat("🎉", i%5))
+	}
+	// A long line without spaces has to be cut between characters
+	for i := 0; i < 60; i++ {
+		b.WriteString(emoji[i%len(emoji)])
+	}
+	md := b.String()
+
+	parts := chunk(t, md, 120, UTF16Length)
+	joined := strings.Join(unlabel(parts), "")
+	if withoutBreaks(joined) != withoutBreaks(md) {
+		t.Errorf("content changed:\n got %q\nwant %q", withoutBreaks(joined), withoutBreaks(md))
+	}
+	for _, e := range emoji {
+		if got, want := strings.Count(joined, e), strings.Count(md, e); got != want {
+			t.Errorf("%q appears %d times, want %d: a sequence was split", e, got, want)
+		}
+	}
+}
+
+func TestChunkCountsUTF16ForTelegram(t *testing.T) {
+	// 60 runes but 120 UTF-16 units
+	md := strings.Repeat("😀", 60)
+	if parts := Chunk(md, 100, utf8.RuneCountInString); len(parts) != 1 {
+		t.Errorf("got %d parts by runes, want 1", len(parts))
+	}
+	if parts := chunk(t, md, 100, UTF16Length); len(parts) != 2 {
+		t.Errorf("got %d parts by UTF-16 units, want 2", len(parts))
+	}
+}
+
+func TestChunkCodeHeavy(t *testing.T) {
+	var code []string
+	for i := 0; i < 120; i++ {
+		code = append(code, fmt.Sprintf("\tfmt.Println(%d, \"`backticks` and ~~~ tildes\")", i))
+	}
+	code = append(code, "", "\t// After a blank line")
+	md := "Here is the program:\n\n```go\nfunc main() {\n" + strings.Join(code, "\n") + "\n}\n```\n\nAnd a second block:\n\n~~~\nplain\n~~~"
+
+	parts := chunk(t, md, 500, utf8.RuneCountInString)
+	if len(parts) < 5 {
+		t.Fatalf("got %d parts, want the code split across several", len(parts))
+	}
+
+	var blocks []string
+	for i, part := range unlabel(parts) {
+		// Every part renders on its own, with its code blocks closed
+		fences := 0
+		for _, line := range strings.Split(part, "\n") {
+			if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+				fences++
+			}
+		}
+		if fences%2 != 0 {
+			t.Errorf("part %d leaves a code block open:\n%s", i+1, part)
+		}
+		if i > 0 && i < len(parts)-2 && !strings.HasPrefix(part, "```go\n") {
+			t.Errorf("part %d doesn't reopen the code block:\n%s", i+1, part)
+		}
+		for _, block := range Parse(part) {
+			if block.Kind == Code && block.Lang == "go" {
+				blocks = append(blocks, block.Text)
+			}
+		}
+	}
+
+	// The pieces of the code add up to the original
+	want := "func main() {\n" + strings.Join(code, "\n") + "\n}"
+	if got := strings.Join(blocks, "\n"); withoutBreaks(got) != withoutBreaks(want) {
+		t.Errorf("code changed:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestChunkCutsLongCodeLines(t *testing.T) {
+	md := "```\n" + strings.Repeat("x", 250) + "\n```"
+	parts := chunk(t, md, 100, utf8.RuneCountInString)
+	for i, part := range unlabel(parts) {
+		if !strings.HasPrefix(part, "```\n") || !strings.HasSuffix(part, "\n```") {
+			t.Errorf("part %d is not a closed code block: %q", i+1, part)
+		}
+	}
+}
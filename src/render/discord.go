@@ -0,0 +1,107 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiscordMarkdown rewrites Markdown into the dialect Discord renders: "__" underlines
+// there, headings stop at level 3, and tables and rules aren't supported, so emphasis
+// is normalized, tables are laid out as text in code blocks and rules become a line
+func DiscordMarkdown(md string) string {
+	return discordBlocks(Parse(md))
+}
+
+// discordBlocks renders blocks as Discord Markdown
+func discordBlocks(blocks []Block) string {
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		switch block.Kind {
+		case Paragraph:
+			parts = append(parts, discordInline(ParseInline(block.Text)))
+		case Heading:
+			parts = append(parts, strings.Repeat("#", min(block.Level, 3))+" "+discordInline(ParseInline(block.Text)))
+		case Code:
+			fence := codeFence(block.Text)
+			parts = append(parts, fence+codeLanguage(block.Lang)+"\n"+block.Text+"\n"+fence)
+		case List:
+			lines := make([]string, len(block.Items))
+			for i, item := range block.Items {
+				marker := "- "
+				switch {
+				case item.Task && item.Checked:
+					marker = "- ☑ "
+				case item.Task:
+					marker = "- ☐ "
+				case item.Ordered:
+					marker = fmt.Sprintf("%d. ", item.Number)
+				}
+				lines[i] = strings.Repeat("  ", item.Depth) + marker + discordInline(ParseInline(item.Text))
+			}
+			parts = append(parts, strings.Join(lines, "\n"))
+		case Quote:
+			lines := strings.Split(discordBlocks(block.Children), "\n")
+			for i, line := range lines {
+				lines[i] = "> " + line
+			}
+			parts = append(parts, strings.Join(lines, "\n"))
+		case Table:
+			parts = append(parts, "```\n"+formatTable(block.Rows)+"\n```")
+		case Rule:
+			parts = append(parts, ruleText)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// discordInline renders spans as Discord Markdown
+func discordInline(spans []Inline) string {
+	var b strings.Builder
+	for _, span := range spans {
+		switch span.Kind {
+		case Text:
+			b.WriteString(escapeDiscord(span.Text))
+		case Bold:
+			b.WriteString("**" + discordInline(span.Children) + "**")
+		case Italic:
+			b.WriteString("*" + discordInline(span.Children) + "*")
+		case Strike:
+			b.WriteString("~~" + discordInline(span.Children) + "~~")
+		case InlineCode:
+			if strings.Contains(span.Text, "`") {
+				b.WriteString("`` " + span.Text + " ``")
+			} else {
+				b.WriteString("`" + span.Text + "`")
+			}
+		case Link:
+			text := PlainText(span.Children)
+			switch {
+			case !hasScheme(span.URL):
+				b.WriteString(escapeDiscord(linkText(span)))
+			case text == span.URL:
+				b.WriteString(span.URL)
+			default:
+				b.WriteString("[" + escapeDiscord(text) + "](" + span.URL + ")")
+			}
+		}
+	}
+	return b.String()
+}
+
+// codeFence returns a fence longer than any run of backticks in code
+func codeFence(code string) string {
+	longest := 0
+	for i := 0; i < len(code); i++ {
+		if code[i] == '`' {
+			n := countRun(code, i, '`')
+			longest = max(longest, n)
+			i += n - 1
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// escapeDiscord escapes the characters Discord reads as formatting
+func escapeDiscord(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`).Replace(s)
+}
@@ -0,0 +1,33 @@
+package render
+
+import "testing"
+
+func TestDiscordMarkdown(t *testing.T) {
+	for _, tc := range []struct {
+		name, md, want string
+	}{
+		{"no HTML escaping", "a & b <c> d > e", "a & b <c> d > e"},
+		{"formatting characters", "text_with_under * star ~ | x", `text\_with\_under \* star \~ \| x`},
+		{"emphasis", "__bold__ _it_ ~~s~~", "**bold** *it* ~~s~~"},
+		{"inline code", "`a<b` and `` x`y ``", "`a<b` and `` x`y ``"},
+		{"link", "[docs](https://example.com/a?b=1&c=2)", "[docs](https://example.com/a?b=1&c=2)"},
+		{"link text", "[a|b*c](https://x.io)", `[a\|b\*c](https://x.io)`},
+		{"autolink", "see <https://example.com>", "see https://example.com"},
+		{"obsidian link", "[note](obsidian://open?vault=v)", "[note](obsidian://open?vault=v)"},
+		{"javascript link", "[run](javascript:alert(1))", "run (javascript:alert(1))"},
+		{"relative link", "[file](notes/a.md)", "file (notes/a.md)"},
+		{"nested lists and tasks", "- one\n  - two\n    1. three\n- [ ] todo\n- [x] done",
+			"- one\n  - two\n    1. three\n- ☐ todo\n- ☑ done"},
+		{"table", "| a | b |\n|---|---|\n| 1 | <2> |", "```\na │ b\n──┼────\n1 │ <2>\n```"},
+		{"code fence", "```go\nif a < b && c {}\n```", "```go\nif a < b && c {}\n```"},
+		{"code containing a fence", "~~~\nuse ``` here\n~~~", "````\nuse ``` here\n````"},
+		{"quotes", "> **hi** & <b>\n> > nested", "> **hi** & <b>\n> \n> > nested"},
+		{"deep heading and rule", "##### Small\n\n---", "### Small\n\n" + ruleText},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := DiscordMarkdown(tc.md); got != tc.want {
+				t.Errorf("got  %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
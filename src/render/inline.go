@@ -0,0 +1,254 @@
+package render
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// InlineKind is the type of an inline Markdown element
+type InlineKind int
+
+const (
+	Text InlineKind = iota
+	Bold
+	Italic
+	Strike
+	InlineCode
+	Link
+)
+
+// Inline is a span of text, possibly formatted
+type Inline struct {
+	Kind     InlineKind
+	Text     string   // Text or code
+	URL      string   // Link target
+	Children []Inline // Content of emphasis and links
+}
+
+// ParseInline splits inline Markdown into spans
+func ParseInline(s string) []Inline {
+	var spans []Inline
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			spans = append(spans, Inline{Kind: Text, Text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+
+		case c == '`':
+			n := countRun(s, i, '`')
+			if end := strings.Index(s[i+n:], s[i:i+n]); end >= 0 {
+				code := s[i+n : i+n+end]
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+					code = code[1 : len(code)-1]
+				}
+				flush()
+				spans = append(spans, Inline{Kind: InlineCode, Text: code})
+				i += n + end + n
+				continue
+			}
+			text.WriteString(s[i : i+n])
+			i += n
+			continue
+
+		case c == '*' || c == '_' || c == '~':
+			if span, end, ok := parseEmphasis(s, i); ok {
+				flush()
+				spans = append(spans, span)
+				i = end
+				continue
+			}
+			// Not emphasis: keep the whole run of markers as text
+			n := countRun(s, i, c)
+			text.WriteString(s[i : i+n])
+			i += n
+			continue
+
+		case c == '[' || (c == '!' && i+1 < len(s) && s[i+1] == '['):
+			if span, end, ok := parseLink(s, i); ok {
+				flush()
+				spans = append(spans, span)
+				i = end
+				continue
+			}
+
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				url := s[i+1 : i+end]
+				if hasScheme(url) && !strings.ContainsAny(url, " \t\n<") {
+					flush()
+					spans = append(spans, Inline{Kind: Link, URL: url, Children: []Inline{{Kind: Text, Text: url}}})
+					i += end + 1
+					continue
+				}
+			}
+		}
+
+		text.WriteByte(c)
+		i++
+	}
+	flush()
+	return spans
+}
+
+// parseEmphasis parses bold, italic or strikethrough text starting at s[i]
+func parseEmphasis(s string, i int) (Inline, int, bool) {
+	c := s[i]
+	n := countRun(s, i, c)
+	var kind InlineKind
+	switch {
+	case c == '~' && n == 2:
+		kind = Strike
+	case c == '~':
+		return Inline{}, 0, false
+	case n == 2:
+		kind = Bold
+	case n == 1:
+		kind = Italic
+	default:
+		return Inline{}, 0, false
+	}
+
+	// The opener must be followed by text, and "_" must not be inside a word (snake_case)
+	start := i + n
+	if start >= len(s) || isSpace(s, start) {
+		return Inline{}, 0, false
+	}
+	if c == '_' && i > 0 && isWordBefore(s, i) {
+		return Inline{}, 0, false
+	}
+
+	// The closer is the same run of markers, preceded by text
+	for j := start + 1; j+n <= len(s); j++ {
+		if s[j] != c {
+			continue
+		}
+		run := countRun(s, j, c)
+		if run != n || isSpace(s, j-1) || (c == '_' && j+n < len(s) && isWordAfter(s, j+n)) {
+			j += run - 1
+			continue
+		}
+		return Inline{Kind: kind, Children: ParseInline(s[start:j])}, j + n, true
+	}
+	return Inline{}, 0, false
+}
+
+// parseLink parses [text](url) or ![alt](url) starting at s[i]. Wikilinks are left as text.
+func parseLink(s string, i int) (Inline, int, bool) {
+	open := i
+	if s[i] == '!' {
+		open++
+	}
+	if open+1 < len(s) && s[open+1] == '[' {
+		return Inline{}, 0, false
+	}
+
+	// Find the closing bracket, allowing nested brackets
+	depth, close := 0, -1
+	for j := open; j < len(s) && close < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			if depth--; depth == 0 {
+				close = j
+			}
+		}
+	}
+	if close < 0 || close+1 >= len(s) || s[close+1] != '(' {
+		return Inline{}, 0, false
+	}
+
+	// Find the closing parenthesis, allowing balanced parentheses in the URL
+	depth, end := 0, -1
+	for j := close + 1; j < len(s) && end < 0; j++ {
+		switch s[j] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				end = j
+			}
+		}
+	}
+	if end < 0 {
+		return Inline{}, 0, false
+	}
+
+	target := strings.TrimSpace(s[close+2 : end])
+	url, _, _ := strings.Cut(target, " ") // Drop a title: (url "title")
+	url = strings.TrimSuffix(strings.TrimPrefix(url, "<"), ">")
+	label := s[open+1 : close]
+	if label == "" {
+		label = url
+	}
+	return Inline{Kind: Link, URL: url, Children: ParseInline(label)}, end + 1, true
+}
+
+// PlainText returns the text of spans without formatting
+func PlainText(spans []Inline) string {
+	var b strings.Builder
+	for _, span := range spans {
+		switch span.Kind {
+		case Text, InlineCode:
+			b.WriteString(span.Text)
+		default:
+			b.WriteString(PlainText(span.Children))
+		}
+	}
+	return b.String()
+}
+
+// hasScheme reports whether a URL is absolute and safe to link, e.g. https://...
+func hasScheme(url string) bool {
+	lower := strings.ToLower(url)
+	for _, scheme := range []string{"http://", "https://", "mailto:", "tg://", "obsidian://"} {
+		if strings.HasPrefix(lower, scheme) && len(url) > len(scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// countRun counts the consecutive c bytes starting at s[i]
+func countRun(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// isPunct reports whether c is ASCII punctuation, which a backslash escapes
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+// isSpace reports whether s[i] is whitespace
+func isSpace(s string, i int) bool {
+	return s[i] == ' ' || s[i] == '\t' || s[i] == '\n'
+}
+
+// isWordBefore reports whether the rune ending before s[i] is a letter or digit
+func isWordBefore(s string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isWordAfter reports whether the rune starting at s[i] is a letter or digit
+func isWordAfter(s string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+)
+
+// text is a plain text span
+func text(s string) Inline {
+	return Inline{Kind: Text, Text: s}
+}
+
+func TestParseInline(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want []Inline
+	}{
+		{"text", "a & b <c>", []Inline{text("a & b <c>")}},
+		{"nested emphasis", "**bold _it_**", []Inline{
+			{Kind: Bold, Children: []Inline{text("bold "), {Kind: Italic, Children: []Inline{text("it")}}}},
+		}},
+		{"strikethrough", "~~s~~ ~t~", []Inline{{Kind: Strike, Children: []Inline{text("s")}}, text(" ~t~")}},
+		{"snake case", "snake_case_name", []Inline{text("snake_case_name")}},
+		{"spaced stars", "2 * 3 * 4", []Inline{text("2 * 3 * 4")}},
+		{"escapes", `\*not\* a \_b`, []Inline{text("*not* a _b")}},
+		{"code", "`` a`b `` and `*x*`", []Inline{
+			{Kind: InlineCode, Text: "a`b"}, text(" and "), {Kind: InlineCode, Text: "*x*"},
+		}},
+		{"unclosed code", "a ` b", []Inline{text("a ` b")}},
+		{"link", `[x](https://a.io/(b) "title")`, []Inline{
+			{Kind: Link, URL: "https://a.io/(b)", Children: []Inline{text("x")}},
+		}},
+		{"image", "![alt](https://x/y.png)", []Inline{
+			{Kind: Link, URL: "https://x/y.png", Children: []Inline{text("alt")}},
+		}},
+		{"relative link", "[file](notes/a.md)", []Inline{
+			{Kind: Link, URL: "notes/a.md", Children: []Inline{text("file")}},
+		}},
+		{"wikilinks", "[[Wiki]] and [[A|b]]", []Inline{text("[[Wiki]] and [[A|b]]")}},
+		{"autolinks", "<notalink> <mailto:a@b.c>", []Inline{
+			text("<notalink> "),
+			{Kind: Link, URL: "mailto:a@b.c", Children: []Inline{text("mailto:a@b.c")}},
+		}},
+		{"autolink without a safe scheme", "<javascript:alert(1)>", []Inline{text("<javascript:alert(1)>")}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ParseInline(tc.in); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHasScheme(t *testing.T) {
+	for url, want := range map[string]bool{
+		"https://example.com":   true,
+		"HTTP://example.com":    true,
+		"mailto:a@b.c":          true,
+		"tg://resolve?domain=x": true,
+		"obsidian://open?v=x":   true,
+		"https://":              false,
+		"javascript:alert(1)":   false,
+		"data:text/html,x":      false,
+		"file:///etc/passwd":    false,
+		"notes/a.md":            false,
+	} {
+		if got := hasScheme(url); got != want {
+			t.Errorf("hasScheme(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
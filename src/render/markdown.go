@@ -0,0 +1,259 @@
+// Package render converts the standard Markdown written by the AI into each chat
+// platform's own format: Telegram HTML, Slack mrkdwn and Block Kit, or plain text.
+//
+// Only the Markdown that chat answers use is understood: paragraphs, headings,
+// fenced code blocks, lists, block quotes, tables, rules and inline emphasis,
+// code and links. Everything else passes through as text.
+package render
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// BlockKind is the type of a Markdown block
+type BlockKind int
+
+const (
+	Paragraph BlockKind = iota
+	Heading
+	Code
+	List
+	Quote
+	Table
+	Rule
+)
+
+// Block is a top-level element of a Markdown document
+type Block struct {
+	Kind     BlockKind
+	Level    int        // Heading level (1-6)
+	Text     string     // Inline Markdown of a paragraph or heading, or the content of a code block
+	Lang     string     // Code block language
+	Items    []ListItem // List items
+	Children []Block    // Blocks inside a quote
+	Rows     [][]string // Table cells (inline Markdown); the first row is the header
+}
+
+// ListItem is an item of a (possibly nested) list
+type ListItem struct {
+	Depth   int    // Nesting level (0 = top)
+	Ordered bool   // Numbered item
+	Number  int    // Number of an ordered item
+	Task    bool   // Task list item ("- [ ] ...")
+	Checked bool   // Completed task
+	Text    string // Inline Markdown
+}
+
+var (
+	headingPattern   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	rulePattern      = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	listPattern      = regexp.MustCompile(`^([ \t]*)([-*+]|\d{1,9}[.)])[ \t]+(.*)$`)
+	quotePattern     = regexp.MustCompile(`^ {0,3}>[ ]?(.*)$`)
+	fencePattern     = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	delimiterPattern = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	taskPattern      = regexp.MustCompile(`^\[([ xX])\][ \t]+`)
+)
+
+// Parse splits Markdown into blocks
+func Parse(md string) []Block {
+	md = strings.ReplaceAll(md, "\r\n", "\n")
+	return parseBlocks(strings.Split(md, "\n"))
+}
+
+// parseBlocks parses lines into blocks
+func parseBlocks(lines []string) []Block {
+	var blocks []Block
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fencePattern.MatchString(line):
+			var block Block
+			block, i = parseFence(lines, i)
+			blocks = append(blocks, block)
+
+		case headingPattern.MatchString(line):
+			m := headingPattern.FindStringSubmatch(line)
+			blocks = append(blocks, Block{Kind: Heading, Level: len(m[1]), Text: m[2]})
+			i++
+
+		case rulePattern.MatchString(line):
+			blocks = append(blocks, Block{Kind: Rule})
+			i++
+
+		case isTableStart(lines, i):
+			var block Block
+			block, i = parseTable(lines, i)
+			blocks = append(blocks, block)
+
+		case quotePattern.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && quotePattern.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quotePattern.FindStringSubmatch(lines[i])[1])
+			}
+			blocks = append(blocks, Block{Kind: Quote, Children: parseBlocks(quoted)})
+
+		case listPattern.MatchString(line):
+			var block Block
+			block, i = parseList(lines, i)
+			blocks = append(blocks, block)
+
+		default:
+			var paragraph []string
+			for ; i < len(lines) && !startsBlock(lines, i); i++ {
+				paragraph = append(paragraph, strings.TrimSpace(lines[i]))
+			}
+			blocks = append(blocks, Block{Kind: Paragraph, Text: strings.Join(paragraph, "\n")})
+		}
+	}
+	return blocks
+}
+
+// startsBlock reports whether line i ends a paragraph
+func startsBlock(lines []string, i int) bool {
+	line := lines[i]
+	return strings.TrimSpace(line) == "" ||
+		fencePattern.MatchString(line) ||
+		headingPattern.MatchString(line) ||
+		rulePattern.MatchString(line) ||
+		quotePattern.MatchString(line) ||
+		listPattern.MatchString(line) ||
+		isTableStart(lines, i)
+}
+
+// parseFence parses a fenced code block starting at line i. An unclosed fence runs to the end.
+func parseFence(lines []string, i int) (Block, int) {
+	m := fencePattern.FindStringSubmatch(lines[i])
+	indent, fence := len(m[1]), m[2]
+	lang, _, _ := strings.Cut(strings.TrimSpace(m[3]), " ")
+
+	var code []string
+	for i++; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+		line := lines[i]
+		for n := 0; n < indent && strings.HasPrefix(line, " "); n++ {
+			line = line[1:]
+		}
+		code = append(code, line)
+	}
+	return Block{Kind: Code, Lang: lang, Text: strings.Join(code, "\n")}, i
+}
+
+// isTableStart reports whether line i is a table header followed by a delimiter row
+func isTableStart(lines []string, i int) bool {
+	return i+1 < len(lines) &&
+		strings.Contains(lines[i], "|") &&
+		strings.Contains(lines[i+1], "-") &&
+		delimiterPattern.MatchString(lines[i+1])
+}
+
+// parseTable parses a table starting at line i
+func parseTable(lines []string, i int) (Block, int) {
+	block := Block{Kind: Table, Rows: [][]string{splitRow(lines[i])}}
+	for i += 2; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+		block.Rows = append(block.Rows, splitRow(lines[i]))
+	}
+	return block, i
+}
+
+// splitRow splits a table row into cells, keeping escaped pipes
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// parseList parses consecutive list items starting at line i. Indented lines continue
+// the previous item, and a blank line ends the list unless another item follows.
+func parseList(lines []string, i int) (Block, int) {
+	var block = Block{Kind: List}
+	var indents []int // Indentation of each open nesting level
+
+	for i < len(lines) {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			// Continue past blank lines only into another item
+			j := i + 1
+			for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+				j++
+			}
+			if j < len(lines) && listPattern.MatchString(lines[j]) {
+				i = j
+				continue
+			}
+			break
+		}
+
+		m := listPattern.FindStringSubmatch(line)
+		if m == nil {
+			// Lazy continuation of the previous item
+			if startsBlock(lines, i) || len(block.Items) == 0 {
+				break
+			}
+			last := &block.Items[len(block.Items)-1]
+			last.Text += "\n" + strings.TrimSpace(line)
+			i++
+			continue
+		}
+
+		indent := width(m[1])
+		for len(indents) > 0 && indent < indents[len(indents)-1] {
+			indents = indents[:len(indents)-1]
+		}
+		if len(indents) == 0 || indent > indents[len(indents)-1] {
+			indents = append(indents, indent)
+		}
+
+		item := ListItem{Depth: len(indents) - 1, Text: m[3]}
+		if marker := m[2]; marker[0] >= '0' && marker[0] <= '9' {
+			item.Ordered = true
+			item.Number, _ = strconv.Atoi(marker[:len(marker)-1])
+		}
+		if task := taskPattern.FindStringSubmatch(item.Text); task != nil {
+			item.Task, item.Checked = true, task[1] != " "
+			item.Text = item.Text[len(task[0]):]
+		}
+		block.Items = append(block.Items, item)
+		i++
+	}
+	return block, i
+}
+
+// width returns the width of leading whitespace, counting tabs as four spaces
+func width(indent string) int {
+	n := 0
+	for _, c := range indent {
+		if c == '\t' {
+			n += 4
+		} else {
+			n++
+		}
+	}
+	return n
+}
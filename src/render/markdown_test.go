@@ -0,0 +1,92 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		md   string
+		want []Block
+	}{
+		{
+			"paragraphs and headings",
+			"## Head ##\n***\nline one\r\nline two\n# next",
+			[]Block{
+				{Kind: Heading, Level: 2, Text: "Head"},
+				{Kind: Rule},
+				{Kind: Paragraph, Text: "line one\nline two"},
+				{Kind: Heading, Level: 1, Text: "next"},
+			},
+		},
+		{
+			"nested lists and tasks",
+			"- one\n  - two\n    1. three\n- [ ] todo\n- [x] done",
+			[]Block{{Kind: List, Items: []ListItem{
+				{Text: "one"},
+				{Depth: 1, Text: "two"},
+				{Depth: 2, Ordered: true, Number: 1, Text: "three"},
+				{Task: true, Text: "todo"},
+				{Task: true, Checked: true, Text: "done"},
+			}}},
+		},
+		{
+			"list continuations and blank lines",
+			"1) first\n   continued\n\n2) second\n\t- tab\n\npara",
+			[]Block{
+				{Kind: List, Items: []ListItem{
+					{Ordered: true, Number: 1, Text: "first\ncontinued"},
+					{Ordered: true, Number: 2, Text: "second"},
+					{Depth: 1, Text: "tab"},
+				}},
+				{Kind: Paragraph, Text: "para"},
+			},
+		},
+		{
+			"code fences",
+			"```go title\nif a < b {\n  # not a heading\n}\n```\n~~~\n```\n~~~",
+			[]Block{
+				{Kind: Code, Lang: "go", Text: "if a < b {\n  # not a heading\n}"},
+				{Kind: Code, Text: "```"},
+			},
+		},
+		{
+			"unclosed fence runs to the end",
+			"```py\nx = 1",
+			[]Block{{Kind: Code, Lang: "py", Text: "x = 1"}},
+		},
+		{
+			"tables",
+			"| a \\| b | c |\n|:-|-:|\n| 1 |\n\nafter",
+			[]Block{
+				{Kind: Table, Rows: [][]string{{"a | b", "c"}, {"1"}}},
+				{Kind: Paragraph, Text: "after"},
+			},
+		},
+		{
+			"pipes without a delimiter row are text",
+			"a | b\nc | d",
+			[]Block{{Kind: Paragraph, Text: "a | b\nc | d"}},
+		},
+		{
+			"quotes",
+			"> # H\n> - i\n> > nested\n\nafter",
+			[]Block{
+				{Kind: Quote, Children: []Block{
+					{Kind: Heading, Level: 1, Text: "H"},
+					{Kind: List, Items: []ListItem{{Text: "i"}}},
+					{Kind: Quote, Children: []Block{{Kind: Paragraph, Text: "nested"}}},
+				}},
+				{Kind: Paragraph, Text: "after"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Parse(tc.md); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ruleText stands in for a horizontal rule
+const ruleText = "──────────"
+
+// Plain renders Markdown as plain text, e.g. when a formatted message is rejected
+func Plain(md string) string {
+	return plainBlocks(Parse(md))
+}
+
+// plainBlocks renders blocks as plain text
+func plainBlocks(blocks []Block) string {
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		switch block.Kind {
+		case Paragraph, Heading:
+			parts = append(parts, plainInline(ParseInline(block.Text)))
+		case Code:
+			parts = append(parts, block.Text)
+		case List:
+			lines := make([]string, len(block.Items))
+			for i, item := range block.Items {
+				lines[i] = listPrefix(item) + plainInline(ParseInline(item.Text))
+			}
+			parts = append(parts, strings.Join(lines, "\n"))
+		case Quote:
+			lines := strings.Split(plainBlocks(block.Children), "\n")
+			for i, line := range lines {
+				lines[i] = "│ " + line
+			}
+			parts = append(parts, strings.Join(lines, "\n"))
+		case Table:
+			parts = append(parts, formatTable(block.Rows))
+		case Rule:
+			parts = append(parts, ruleText)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// plainInline renders spans as text, keeping link targets
+func plainInline(spans []Inline) string {
+	var b strings.Builder
+	for _, span := range spans {
+		switch span.Kind {
+		case Text, InlineCode:
+			b.WriteString(span.Text)
+		case Link:
+			b.WriteString(linkText(span))
+		default:
+			b.WriteString(plainInline(span.Children))
+		}
+	}
+	return b.String()
+}
+
+// linkText describes a link as "text (url)", or just the URL if that is its text
+func linkText(link Inline) string {
+	text := PlainText(link.Children)
+	if text == link.URL || link.URL == "" {
+		return text
+	}
+	return fmt.Sprintf("%s (%s)", text, link.URL)
+}
+
+// listPrefix returns the indentation and marker of a list item
+func listPrefix(item ListItem) string {
+	marker := "• "
+	switch {
+	case item.Task && item.Checked:
+		marker = "☑ "
+	case item.Task:
+		marker = "☐ "
+	case item.Ordered:
+		marker = fmt.Sprintf("%d. ", item.Number)
+	}
+	return strings.Repeat("    ", item.Depth) + marker
+}
+
+// formatTable lays out table cells in aligned columns for a monospace font
+func formatTable(rows [][]string) string {
+	cells := make([][]string, len(rows))
+	var widths []int
+	for r, row := range rows {
+		cells[r] = make([]string, len(row))
+		for c, cell := range row {
+			cells[r][c] = PlainText(ParseInline(cell))
+			if c >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[c] = max(widths[c], len([]rune(cells[r][c])))
+		}
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for r, row := range cells {
+		padded := make([]string, len(widths))
+		for c := range widths {
+			var cell string
+			if c < len(row) {
+				cell = row[c]
+			}
+			padded[c] = cell + strings.Repeat(" ", widths[c]-len([]rune(cell)))
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(padded, " │ "), " "))
+
+		if r == 0 {
+			rule := make([]string, len(widths))
+			for c, w := range widths {
+				rule[c] = strings.Repeat("─", w)
+			}
+			lines = append(lines, strings.Join(rule, "─┼─"))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// codeLanguage returns a code block language safe to put in a class name, or ""
+func codeLanguage(lang string) string {
+	for _, c := range lang {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("+-_#.", c)) {
+			return ""
+		}
+	}
+	return lang
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// splitLines splits text at line breaks into pieces of at most n runes,
+// cutting lines that are longer on their own
+func splitLines(text string, n int) []string {
+	var pieces []string
+	var piece []rune
+	for _, line := range strings.SplitAfter(text, "\n") {
+		runes := []rune(line)
+		if len(piece)+len(runes) > n && len(piece) > 0 {
+			pieces = append(pieces, strings.TrimSuffix(string(piece), "\n"))
+			piece = nil
+		}
+		for len(runes) > n {
+			pieces = append(pieces, string(runes[:n]))
+			runes = runes[n:]
+		}
+		piece = append(piece, runes...)
+	}
+	if len(piece) > 0 {
+		pieces = append(pieces, strings.TrimSuffix(string(piece), "\n"))
+	}
+	return pieces
+}
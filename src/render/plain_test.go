@@ -0,0 +1,29 @@
+package render
+
+import "testing"
+
+func TestPlain(t *testing.T) {
+	for _, tc := range []struct {
+		name, md, want string
+	}{
+		{"no escaping", "a & b <c> d > e", "a & b <c> d > e"},
+		{"emphasis", "**bold _it_** ~~s~~ `*x*`", "bold it s *x*"},
+		{"link", "[docs](https://example.com/a?b=1&c=2)", "docs (https://example.com/a?b=1&c=2)"},
+		{"autolink", "see <https://example.com>", "see https://example.com"},
+		{"obsidian link", "[note](obsidian://open?vault=v)", "note (obsidian://open?vault=v)"},
+		{"javascript link", "[run](javascript:alert(1))", "run (javascript:alert(1))"},
+		{"nested lists and tasks", "- one\n  - two\n    1. three\n- [ ] todo\n- [x] done",
+			"• one\n    • two\n        1. three\n☐ todo\n☑ done"},
+		{"table", "| a | b |\n|---|---|\n| 1 | <2> |", "a │ b\n──┼────\n1 │ <2>"},
+		{"ragged table", "| name | n |\n|-|-|\n| **long name** |\n| x | 10 |", "name      │ n\n──────────┼───\nlong name │\nx         │ 10"},
+		{"code fence", "```go\nif a < b && c {}\n```", "if a < b && c {}"},
+		{"quotes", "> **hi** & <b>\n> > nested", "│ hi & <b>\n│ \n│ │ nested"},
+		{"heading and rule", "# Title *x*\n\n***", "Title x\n\n" + ruleText},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Plain(tc.md); got != tc.want {
+				t.Errorf("got  %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
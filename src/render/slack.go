@@ -0,0 +1,130 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// maxSlackSectionText is the length limit of a section block's text
+	maxSlackSectionText = 3000
+	// maxSlackHeaderText is the length limit of a header block's text
+	maxSlackHeaderText = 150
+)
+
+// SlackMrkdwn renders Markdown as Slack mrkdwn text
+func SlackMrkdwn(md string) string {
+	blocks := Parse(md)
+	parts := make([]string, len(blocks))
+	for i, block := range blocks {
+		parts[i] = slackBlock(block)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// SlackBlocks renders Markdown as Block Kit blocks: headings become header blocks,
+// rules become dividers, and everything else is grouped into mrkdwn sections
+func SlackBlocks(md string) []slack.Block {
+	var blocks []slack.Block
+	var section []string
+	sectionLength := 0
+	flush := func() {
+		if len(section) > 0 {
+			text := slack.NewTextBlockObject(slack.MarkdownType, strings.Join(section, "\n\n"), false, false)
+			blocks = append(blocks, slack.NewSectionBlock(text, nil, nil))
+			section, sectionLength = nil, 0
+		}
+	}
+
+	for _, block := range Parse(md) {
+		switch block.Kind {
+		case Heading:
+			flush()
+			text := truncate(PlainText(ParseInline(block.Text)), maxSlackHeaderText)
+			blocks = append(blocks, slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, text, true, false)))
+		case Rule:
+			flush()
+			blocks = append(blocks, slack.NewDividerBlock())
+		default:
+			for _, text := range splitLines(slackBlock(block), maxSlackSectionText) {
+				length := len([]rune(text))
+				if sectionLength > 0 && sectionLength+2+length > maxSlackSectionText {
+					flush()
+				}
+				section = append(section, text)
+				sectionLength += length + 2
+			}
+		}
+	}
+	flush()
+	return blocks
+}
+
+// slackBlock renders a block as mrkdwn
+func slackBlock(block Block) string {
+	switch block.Kind {
+	case Heading:
+		return "*" + escapeSlack(PlainText(ParseInline(block.Text))) + "*"
+	case Code:
+		return "```\n" + escapeSlack(block.Text) + "\n```"
+	case List:
+		lines := make([]string, len(block.Items))
+		for i, item := range block.Items {
+			lines[i] = listPrefix(item) + slackInline(ParseInline(item.Text))
+		}
+		return strings.Join(lines, "\n")
+	case Quote:
+		parts := make([]string, len(block.Children))
+		for i, child := range block.Children {
+			parts[i] = slackBlock(child)
+		}
+		lines := strings.Split(strings.Join(parts, "\n\n"), "\n")
+		for i, line := range lines {
+			lines[i] = "> " + line
+		}
+		return strings.Join(lines, "\n")
+	case Table:
+		return "```\n" + escapeSlack(formatTable(block.Rows)) + "\n```"
+	case Rule:
+		return ruleText
+	}
+	return slackInline(ParseInline(block.Text))
+}
+
+// slackInline renders spans as mrkdwn
+func slackInline(spans []Inline) string {
+	var b strings.Builder
+	for _, span := range spans {
+		switch span.Kind {
+		case Text:
+			b.WriteString(escapeSlack(span.Text))
+		case Bold:
+			b.WriteString("*" + slackInline(span.Children) + "*")
+		case Italic:
+			b.WriteString("_" + slackInline(span.Children) + "_")
+		case Strike:
+			b.WriteString("~" + slackInline(span.Children) + "~")
+		case InlineCode:
+			b.WriteString("`" + escapeSlack(span.Text) + "`")
+		case Link:
+			text := PlainText(span.Children)
+			switch {
+			case !hasScheme(span.URL):
+				b.WriteString(escapeSlack(linkText(span)))
+			case text == span.URL:
+				b.WriteString("<" + escapeSlack(span.URL) + ">")
+			default:
+				// "|" and ">" would end the link text early
+				text = strings.NewReplacer("|", "¦", ">", "›").Replace(text)
+				b.WriteString("<" + escapeSlack(span.URL) + "|" + escapeSlack(text) + ">")
+			}
+		}
+	}
+	return b.String()
+}
+
+// escapeSlack escapes the characters mrkdwn reserves for links and mentions
+func escapeSlack(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
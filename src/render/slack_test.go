@@ -0,0 +1,61 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestSlackMrkdwn(t *testing.T) {
+	for _, tc := range []struct {
+		name, md, want string
+	}{
+		{"escaping", "a & b <c> d > e", "a &amp; b &lt;c&gt; d &gt; e"},
+		{"emphasis", "**bold _it_** ~~s~~ `a<b`", "*bold _it_* ~s~ `a&lt;b`"},
+		{"link", "[docs](https://example.com/a?b=1&c=2)", "<https://example.com/a?b=1&amp;c=2|docs>"},
+		{"link text with separators", "[a|b>c](https://x.io)", "<https://x.io|a¦b›c>"},
+		{"autolink", "see <https://example.com>", "see <https://example.com>"},
+		{"obsidian link", "[note](obsidian://open?vault=v)", "<obsidian://open?vault=v|note>"},
+		{"javascript link", "[run](javascript:alert(1))", "run (javascript:alert(1))"},
+		{"relative link", "[file](notes/a.md)", "file (notes/a.md)"},
+		{"nested lists and tasks", "- one\n  - two\n    1. three\n- [ ] todo\n- [x] done",
+			"• one\n    • two\n        1. three\n☐ todo\n☑ done"},
+		{"table", "| a | b |\n|---|---|\n| 1 | <2> |", "```\na │ b\n──┼────\n1 │ &lt;2&gt;\n```"},
+		{"code fence", "```go\nif a < b && c {}\n```", "```\nif a &lt; b &amp;&amp; c {}\n```"},
+		{"quotes", "> **hi** & <b>\n> > nested", "> *hi* &amp; &lt;b&gt;\n> \n> > nested"},
+		{"heading", "# Title *x* & y", "*Title x &amp; y*"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SlackMrkdwn(tc.md); got != tc.want {
+				t.Errorf("got  %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSlackBlocks(t *testing.T) {
+	blocks := SlackBlocks("# Plan <b>\n\nfirst & second\n\n---\n\n- item")
+	if len(blocks) != 4 {
+		t.Fatalf("got %d blocks, want header, section, divider, section", len(blocks))
+	}
+	header, ok := blocks[0].(*slack.HeaderBlock)
+	if !ok || header.Text.Text != "Plan <b>" || header.Text.Type != slack.PlainTextType {
+		t.Errorf("header is %+v", blocks[0])
+	}
+	if section, ok := blocks[1].(*slack.SectionBlock); !ok || section.Text.Text != "first &amp; second" {
+		t.Errorf("section is %+v", blocks[1])
+	}
+	if _, ok := blocks[2].(*slack.DividerBlock); !ok {
+		t.Errorf("rule is %+v", blocks[2])
+	}
+
+	// Long answers are split into sections within the limit
+	long := strings.Repeat("word ", 1000) + "\n\n" + strings.Repeat("more ", 1000)
+	for i, block := range SlackBlocks(long) {
+		section, ok := block.(*slack.SectionBlock)
+		if !ok || len([]rune(section.Text.Text)) > maxSlackSectionText {
+			t.Errorf("block %d is not a section within the limit", i)
+		}
+	}
+}
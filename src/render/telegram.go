@@ -0,0 +1,79 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TelegramHTML renders Markdown for Telegram's HTML parse mode
+func TelegramHTML(md string) string {
+	return telegramBlocks(Parse(md), false)
+}
+
+// telegramBlocks renders blocks as Telegram HTML. Telegram doesn't nest quotes,
+// so quotes inside a quote are rendered as their content.
+func telegramBlocks(blocks []Block, quoted bool) string {
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		switch block.Kind {
+		case Paragraph:
+			parts = append(parts, telegramInline(ParseInline(block.Text)))
+		case Heading:
+			parts = append(parts, "<b>"+telegramInline(ParseInline(block.Text))+"</b>")
+		case Code:
+			if lang := codeLanguage(block.Lang); lang != "" {
+				parts = append(parts, fmt.Sprintf(`<pre><code class="language-%s">%s</code></pre>`, lang, escapeHTML(block.Text)))
+			} else {
+				parts = append(parts, "<pre>"+escapeHTML(block.Text)+"</pre>")
+			}
+		case List:
+			lines := make([]string, len(block.Items))
+			for i, item := range block.Items {
+				lines[i] = listPrefix(item) + telegramInline(ParseInline(item.Text))
+			}
+			parts = append(parts, strings.Join(lines, "\n"))
+		case Quote:
+			if quoted {
+				parts = append(parts, telegramBlocks(block.Children, true))
+			} else {
+				parts = append(parts, "<blockquote>"+telegramBlocks(block.Children, true)+"</blockquote>")
+			}
+		case Table:
+			parts = append(parts, "<pre>"+escapeHTML(formatTable(block.Rows))+"</pre>")
+		case Rule:
+			parts = append(parts, ruleText)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// telegramInline renders spans as Telegram HTML
+func telegramInline(spans []Inline) string {
+	var b strings.Builder
+	for _, span := range spans {
+		switch span.Kind {
+		case Text:
+			b.WriteString(escapeHTML(span.Text))
+		case Bold:
+			b.WriteString("<b>" + telegramInline(span.Children) + "</b>")
+		case Italic:
+			b.WriteString("<i>" + telegramInline(span.Children) + "</i>")
+		case Strike:
+			b.WriteString("<s>" + telegramInline(span.Children) + "</s>")
+		case InlineCode:
+			b.WriteString("<code>" + escapeHTML(span.Text) + "</code>")
+		case Link:
+			if hasScheme(span.URL) {
+				fmt.Fprintf(&b, `<a href="%s">%s</a>`, escapeHTML(span.URL), telegramInline(span.Children))
+			} else {
+				b.WriteString(escapeHTML(linkText(span)))
+			}
+		}
+	}
+	return b.String()
+}
+
+// escapeHTML escapes the characters Telegram's HTML parse mode reserves
+func escapeHTML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
@@ -0,0 +1,31 @@
+package render
+
+import "testing"
+
+func TestTelegramHTML(t *testing.T) {
+	for _, tc := range []struct {
+		name, md, want string
+	}{
+		{"escaping", "a & b <c> d > e", "a &amp; b &lt;c&gt; d &gt; e"},
+		{"emphasis", "**bold _it_** ~~s~~ `a<b`", "<b>bold <i>it</i></b> <s>s</s> <code>a&lt;b</code>"},
+		{"link", "[docs](https://example.com/a?b=1&c=2)", `<a href="https://example.com/a?b=1&amp;c=2">docs</a>`},
+		{"quotes in a link", `[x](https://x.io/?q="a")`, `<a href="https://x.io/?q=&quot;a&quot;">x</a>`},
+		{"autolink", "see <https://example.com>", `see <a href="https://example.com">https://example.com</a>`},
+		{"obsidian link", "[note](obsidian://open?vault=v)", `<a href="obsidian://open?vault=v">note</a>`},
+		{"javascript link", "[run](javascript:alert(1))", "run (javascript:alert(1))"},
+		{"relative link", "[file](notes/a.md)", "file (notes/a.md)"},
+		{"nested lists and tasks", "- one\n  - two\n    1. three\n- [ ] todo\n- [x] done",
+			"• one\n    • two\n        1. three\n☐ todo\n☑ done"},
+		{"table", "| a | b |\n|---|---|\n| 1 | <2> |", "<pre>a │ b\n──┼────\n1 │ &lt;2&gt;</pre>"},
+		{"code fence", "```go\nif a < b && c {}\n```", `<pre><code class="language-go">if a &lt; b &amp;&amp; c {}</code></pre>`},
+		{"unsafe code language", "```a\"b\nx\n```", "<pre>x</pre>"},
+		{"quotes", "> **hi** & <b>\n> > nested", "<blockquote><b>hi</b> &amp; &lt;b&gt;\n\nnested</blockquote>"},
+		{"heading and rule", "# Title *x*\n\n---", "<b>Title <i>x</i></b>\n\n" + ruleText},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := TelegramHTML(tc.md); got != tc.want {
+				t.Errorf("got  %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Package main provides rendering of executor results shared by the messaging platforms.
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gpng/obsidian-pa/src/executor"
+)
+
+// maxErrorDetail bounds the CLI output included in a failure message, so it fits in one message
+const maxErrorDetail = 1500
+
+// failureMessage returns the user-facing message for a failed run
+func failureMessage(res *executor.Result) string {
+	detail := truncate(res.Error, maxErrorDetail)
+
+	switch res.ErrorKind {
+	case executor.ErrorCancelled:
+		return "🛑 Run cancelled. The AI process was stopped."
+	case executor.ErrorTimeout:
+		return fmt.Sprintf("⏱️ Timed out after %s. The run was aborted; your session is kept.", res.Duration.Round(time.Second))
+	case executor.ErrorAuth:
+		return fmt.Sprintf("🔑 %s authentication failed. Check the API key or log in to the CLI again.\n\n%s", res.Executor, detail)
+	case executor.ErrorRateLimit:
+		return fmt.Sprintf("🚦 %s is rate limited or overloaded. Try again in a moment.\n\n%s", res.Executor, detail)
+	case executor.ErrorCLIMissing:
+		return fmt.Sprintf("🧩 The %s CLI is not installed or not on PATH.", res.Executor)
+	case executor.ErrorParse:
+		return fmt.Sprintf("⚠️ Couldn't understand the %s output:\n%s", res.Executor, detail)
+	}
+	return fmt.Sprintf("❌ Error:\n%s", detail)
+}
+
+// withFallbackNote appends which backend answered when the primary failed over
+func withFallbackNote(res *executor.Result) string {
+	if len(res.Fallbacks) == 0 {
+		return res.Response
+	}
+	return fmt.Sprintf("%s\n\n_↪️ Answered by %s (%s unavailable)_", res.Response, res.Executor, strings.Join(res.Fallbacks, ", "))
+}
+
+// truncate shortens s to at most max runes, marking the cut with an ellipsis
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max]) + "…"
+}
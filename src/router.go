@@ -0,0 +1,269 @@
+// Package main provides the router shared by the messaging platforms: it authenticates
+// messages, handles commands and queues prompts for the AI.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/gpng/obsidian-pa/src/executor"
+)
+
+// Serve routes a platform's messages until it stops receiving
+func (a *App) Serve(p Platform) error {
+	// The direct chat with the allowed user is where /handoff from other platforms lands
+	if home, ok := p.Home(); ok {
+		a.registerHome(p.Name(), platformName(p.Name()), a.sessionKey(p, home), func(text string) {
+			sendMarkdown(p, home, text)
+		})
+	}
+	return p.Receive(func(msg Message) {
+		a.route(p, msg)
+	})
+}
+
+// sessionKey returns the session key of a conversation on a platform
+func (a *App) sessionKey(p Platform, conv Conversation) string {
+	if conv.Direct {
+		return a.directSessionKey(conv.key(p.Name()))
+	}
+	return conv.key(p.Name())
+}
+
+// route handles a message: commands are answered right away, prompts are queued for the AI
+func (a *App) route(p Platform, msg Message) {
+	tag := logTag(p)
+
+	// Authenticate user
+	if !slices.Contains(p.AllowedUsers(), msg.UserID) {
+		log.Printf("%s Unauthorized access attempt from user ID: %s", tag, msg.UserID)
+		return
+	}
+	if msg.Text == "" {
+		return
+	}
+	log.Printf("%s Received message from authorized user: %s", tag, msg.Text)
+
+	conv := msg.Conversation
+	sessionKey, chatKey := a.sessionKey(p, conv), conv.chatKey(p.Name())
+	reply := func(text string) {
+		sendText(p, conv, text)
+	}
+
+	command, arg, isCommand := a.parseCommand(msg.Text, msg.BareCommands)
+	switch command {
+	case "reset":
+		a.resetSession(sessionKey)
+		log.Printf("%s Session reset", tag)
+		reply("🔄 Session reset. Starting fresh conversation.")
+		return
+
+	case "status":
+		reply(a.selectionStatus(chatKey) + "\n" + a.sessionStatus(sessionKey))
+		return
+
+	case "cancel":
+		// Abort the in-flight run and drop queued ones
+		running, dropped := a.jobs.cancel(sessionKey)
+		dropped += a.batches.drop(sessionKey)
+		if running || dropped > 0 {
+			log.Printf("%s Run cancelled by user", tag)
+		}
+		reply(cancelMessage(running, dropped))
+		return
+
+	case "queue":
+		reply(a.jobs.status(sessionKey))
+		return
+
+	case "usage":
+		// Show token usage and cost
+		sendMarkdown(p, conv, a.usageReport())
+		return
+
+	case "model":
+		reply(a.modelCommand(chatKey, arg))
+		return
+
+	case "executor":
+		reply(a.executorCommand(chatKey, arg))
+		return
+
+	case "mode":
+		reply(a.modeCommand(chatKey, arg))
+		return
+
+	case "sessions":
+		reply(a.sessionsCommand(sessionKey))
+		return
+
+	case "session":
+		reply(a.sessionCommand(sessionKey, arg))
+		return
+
+	case "commands":
+		reply(a.commandsCommand())
+		return
+
+	case "handoff":
+		// Writing the recap is a run in this conversation's session, after any collected messages
+		a.batches.flush(sessionKey)
+		position := a.jobs.submit(sessionKey, p.Name(), "Handoff recap", func(ctx context.Context) {
+			reply("🤝 Writing a recap for the handoff...")
+			sendMarkdown(p, conv, a.handoff(ctx, p.Name(), sessionKey, chatKey, arg))
+		})
+		if queued := queuedMessage(position); queued != "" {
+			reply(queued)
+		}
+		return
+	}
+
+	// Refuse new runs once a budget cap is reached
+	if refusal := a.checkBudget(); refusal != "" {
+		reply(refusal)
+		return
+	}
+
+	// Describe the message for the prompt envelope; a new thread replies to its parent message
+	meta := messageMeta{Platform: platformName(p.Name()), Sender: msg.Sender, Sent: msg.Sent, ReplyTo: msg.ReplyTo}
+	if meta.ReplyTo == "" && msg.ThreadParent != nil && !a.Sessions.Get(sessionKey).Active() {
+		meta.ReplyTo = msg.ThreadParent()
+	}
+
+	// Queue runs - the platform must stay free to receive /status, /queue and /cancel
+	submit := func(label string, run func(ctx context.Context)) {
+		if queued := queuedMessage(a.jobs.submit(sessionKey, p.Name(), label, run)); queued != "" {
+			reply(queued)
+		}
+	}
+
+	// Handle prompt commands, such as /start - Read context and start daily review
+	if isCommand {
+		cmd, _ := a.command(command)
+		arg, meta.AsFile = splitFileSuffix(arg)
+		prompt, err := a.commandPrompt(chatKey, cmd, arg)
+		if err != nil {
+			reply(fmt.Sprintf("❌ %v", err))
+			return
+		}
+
+		a.batches.flush(sessionKey)
+		submit("/"+cmd.Name, func(ctx context.Context) {
+			if cmd.NewSession {
+				// Reset session for a fresh start
+				a.resetSession(sessionKey)
+				a.titleSession(sessionKey, commandTitle(cmd))
+				log.Printf("%s Starting new session for /%s", tag, cmd.Name)
+			}
+			a.runPrompt(ctx, p, conv, sessionKey, chatKey, prompt, meta, commandIndicator(cmd))
+		})
+		return
+	}
+
+	// "... as file" asks for the response as a file
+	text := msg.Text
+	if rest, ok := splitFileSuffix(text); ok && rest != "" {
+		text, meta.AsFile = rest, true
+	}
+
+	// Messages sent in quick succession run together as one prompt
+	a.batches.add(sessionKey, chatMessage{Text: text, Meta: meta}, func(parts []chatMessage) {
+		prompt, meta := a.batchPrompt(parts), batchMeta(parts)
+		submit(batchLabel(parts), func(ctx context.Context) {
+			a.runPrompt(ctx, p, conv, sessionKey, chatKey, prompt, meta, "🧠 Processing...")
+		})
+	})
+}
+
+// runPrompt executes a prompt and sends the response, showing an indicator while it runs
+func (a *App) runPrompt(ctx context.Context, p Platform, conv Conversation, sessionKey, chatKey, prompt string, meta messageMeta, indicator string) {
+	tag := logTag(p)
+
+	// Resolve the chat's executor and model, or a one-off "@model" prefix
+	chain, req := a.prepareRun(chatKey, prompt)
+	text := req.Prompt
+	req.Prompt = a.Envelope.Wrap(text, meta)
+	run, ids, forks := a.resumeSessions(sessionKey)
+
+	// Send processing indicator
+	indicatorID, err := p.Send(conv, indicator)
+	if err != nil {
+		log.Printf("%s Failed to send processing message: %v", tag, err)
+	}
+
+	// Edit the indicator in place as the AI reads and writes notes
+	var onEvent executor.EventHandler
+	if err == nil {
+		onEvent = newProgressReporter(func(text string) {
+			if err := p.Edit(conv, indicatorID, text); err != nil {
+				log.Printf("%s Failed to update processing message: %v", tag, err)
+			}
+		}).Handle
+	}
+
+	// Execute AI CLI
+	asked := time.Now()
+	res := chain.Execute(ctx, req, ids, forks, onEvent)
+	a.recordUsage(p.Name(), res)
+
+	// Update the answering executor's session ID (kept on failure so the user can retry)
+	a.recordSession(run, res, text)
+	if res.SessionID != "" {
+		log.Printf("%s %s session ID: %s", tag, res.Executor, res.SessionID)
+	}
+	a.recordJournal(p.Name(), run, asked, text, res)
+
+	// Delete processing message
+	if err == nil {
+		if err := p.Delete(conv, indicatorID); err != nil {
+			log.Printf("%s Failed to delete processing message: %v", tag, err)
+		}
+	}
+
+	// Failures are sent as plain text - CLI output often breaks Markdown
+	if res.Failed() {
+		log.Printf("%s Run failed (%s) after %s", tag, res.ErrorKind, res.Duration)
+		sendText(p, conv, failureMessage(res))
+		return
+	}
+
+	// Send response (as a file if asked for or very long)
+	response := withFallbackNote(res)
+	if a.sendsAsFile(response, meta.AsFile) {
+		sendFile(p, conv, response)
+		return
+	}
+	sendMarkdown(p, conv, response)
+}
+
+// sendText posts plain text, logging failures
+func sendText(p Platform, conv Conversation, text string) {
+	if _, err := p.Send(conv, text); err != nil {
+		log.Printf("%s Failed to send message: %v", logTag(p), err)
+	}
+}
+
+// sendMarkdown posts a Markdown response, telling the user if it can't be delivered
+func sendMarkdown(p Platform, conv Conversation, md string) {
+	if err := p.SendMarkdown(conv, md); err != nil {
+		log.Printf("%s Failed to send response: %v", logTag(p), err)
+		sendText(p, conv, fmt.Sprintf("❌ Failed to send response: %v", err))
+	}
+}
+
+// sendFile posts a response as a Markdown file with a summary, or as messages if the upload fails
+func sendFile(p Platform, conv Conversation, response string) {
+	name, summary := responseFile(response, time.Now())
+	if err := p.Upload(conv, name, []byte(response), summary); err != nil {
+		log.Printf("%s Failed to send response as a file, sending it as messages: %v", logTag(p), err)
+		sendMarkdown(p, conv, response)
+	}
+}
+
+// logTag prefixes a platform's log lines, e.g. "[Telegram]"
+func logTag(p Platform) string {
+	return "[" + platformName(p.Name()) + "]"
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gpng/obsidian-pa/src/executor"
+	"github.com/gpng/obsidian-pa/src/journal"
+	"github.com/gpng/obsidian-pa/src/prefs"
+	"github.com/gpng/obsidian-pa/src/prompts"
+	"github.com/gpng/obsidian-pa/src/sessions"
+)
+
+// fakePlatform records what the router sends instead of talking to a network
+type fakePlatform struct {
+	mu      sync.Mutex
+	sent    []string // Plain text, Markdown and file captions, in order
+	files   []string // Names of uploaded files
+	deleted int
+	nextID  int
+	output  chan string // Receives every Markdown response and upload caption
+}
+
+func newFakePlatform() *fakePlatform {
+	return &fakePlatform{output: make(chan string, 10)}
+}
+
+func (f *fakePlatform) Name() string                            { return "fake" }
+func (f *fakePlatform) AllowedUsers() []string                  { return []string{"owner"} }
+func (f *fakePlatform) Home() (Conversation, bool)              { return Conversation{}, false }
+func (f *fakePlatform) Receive(handle func(Message)) error      { return nil }
+func (f *fakePlatform) Edit(Conversation, string, string) error { return nil }
+
+func (f *fakePlatform) Send(conv Conversation, text string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, text)
+	f.nextID++
+	return fmt.Sprint(f.nextID), nil
+}
+
+func (f *fakePlatform) SendMarkdown(conv Conversation, md string) error {
+	f.mu.Lock()
+	f.sent = append(f.sent, md)
+	f.mu.Unlock()
+	f.output <- md
+	return nil
+}
+
+func (f *fakePlatform) Delete(conv Conversation, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted++
+	return nil
+}
+
+func (f *fakePlatform) Upload(conv Conversation, name string, content []byte, caption string) error {
+	f.mu.Lock()
+	f.files = append(f.files, name)
+	f.sent = append(f.sent, caption)
+	f.mu.Unlock()
+	f.output <- caption
+	return nil
+}
+
+// messages returns everything sent so far
+func (f *fakePlatform) messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.sent...)
+}
+
+// wait returns the next response, failing the test if none arrives
+func (f *fakePlatform) wait(t *testing.T) string {
+	t.Helper()
+	select {
+	case out := <-f.output:
+		return out
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no response; sent so far: %q", f.messages())
+		return ""
+	}
+}
+
+// echoExecutor answers every prompt with its last line and numbers its sessions
+type echoExecutor struct {
+	mu       sync.Mutex
+	requests []executor.Request
+
+	// If set, each run signals started and waits for release before answering
+	started, release chan struct{}
+}
+
+func (e *echoExecutor) Name() string           { return "echo" }
+func (e *echoExecutor) GetStartPrompt() string { return "Start the daily review" }
+
+func (e *echoExecutor) Execute(ctx context.Context, req executor.Request, onEvent executor.EventHandler) *executor.Result {
+	e.mu.Lock()
+	e.requests = append(e.requests, req)
+	n, started, release := len(e.requests), e.started, e.release
+	e.mu.Unlock()
+	if started != nil {
+		started <- struct{}{}
+		<-release
+	}
+
+	lines := strings.Split(strings.TrimSpace(req.Prompt), "\n")
+	sessionID := req.SessionID
+	if sessionID == "" {
+		sessionID = fmt.Sprintf("session-%d", n)
+	}
+	return &executor.Result{Response: "echo: " + lines[len(lines)-1], SessionID: sessionID}
+}
+
+// last returns the last request
+func (e *echoExecutor) last() executor.Request {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.requests[len(e.requests)-1]
+}
+
+// newTestApp returns an app with stores in a temporary directory and the echo executor
+func newTestApp(t *testing.T) (*App, *echoExecutor) {
+	t.Helper()
+	dir := t.TempDir()
+	chatPrefs, err := prefs.Open(filepath.Join(dir, "chats.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionStore, err := sessions.Open(filepath.Join(dir, "sessions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	echo := &echoExecutor{}
+	registry := executor.NewRegistry()
+	registry.Register(echo, "", nil)
+	return &App{
+		Registry: registry,
+		Prefs:    chatPrefs,
+		Mode:     executor.ModeReadOnly,
+		Sessions: sessionStore,
+		jobs:     newJobQueue(1),
+		batches:  newBatcher(0),
+	}, echo
+}
+
+// message is a message from the allowed user in a direct chat
+func message(text string) Message {
+	return Message{Conversation: Conversation{Chat: "chat", Direct: true}, UserID: "owner", Text: text}
+}
+
+func TestRouterIgnoresUnauthorizedUsers(t *testing.T) {
+	app, echo := newTestApp(t)
+	p := newFakePlatform()
+
+	msg := message("hello")
+	msg.UserID = "stranger"
+	app.route(p, msg)
+	app.route(p, Message{Conversation: msg.Conversation, UserID: "stranger", Text: "/status"})
+
+	if sent := p.messages(); len(sent) != 0 {
+		t.Errorf("sent %q to an unauthorized user", sent)
+	}
+	if len(echo.requests) != 0 {
+		t.Error("ran a prompt for an unauthorized user")
+	}
+}
+
+func TestRouterRunsPromptsInSession(t *testing.T) {
+	app, echo := newTestApp(t)
+	p := newFakePlatform()
+
+	app.route(p, message("hello"))
+	if got := p.wait(t); got != "echo: hello" {
+		t.Errorf("got response %q", got)
+	}
+
+	// The processing indicator is removed once the response arrives
+	if sent := p.messages(); len(sent) != 2 || sent[0] != "🧠 Processing..." {
+		t.Errorf("sent %q, want the indicator and the response", sent)
+	}
+	if p.deleted != 1 {
+		t.Errorf("deleted %d messages, want the indicator", p.deleted)
+	}
+
+	// The next message resumes the session
+	app.route(p, message("again"))
+	p.wait(t)
+	if got := echo.last().SessionID; got != "session-1" {
+		t.Errorf("resumed session %q, want session-1", got)
+	}
+
+	// Threads are conversations of their own
+	thread := message("in a thread")
+	thread.Conversation = Conversation{Chat: "chat", Thread: "t1"}
+	app.route(p, thread)
+	p.wait(t)
+	if got := echo.last().SessionID; got != "" {
+		t.Errorf("thread resumed session %q, want a new one", got)
+	}
+	if !app.Sessions.Get("fake:chat:t1").Active() {
+		t.Error("thread session was not recorded under its own key")
+	}
+}
+
+func TestRouterHandlesCommandsWithoutRunning(t *testing.T) {
+	app, echo := newTestApp(t)
+	p := newFakePlatform()
+
+	app.route(p, message("hello"))
+	p.wait(t)
+
+	app.route(p, message("/reset"))
+	if app.Sessions.Get("fake:chat").Active() {
+		t.Error("/reset kept the session")
+	}
+	app.route(p, message("/status"))
+	app.route(p, message("/queue"))
+
+	sent := p.messages()
+	if len(sent) != 5 {
+		t.Fatalf("sent %q, want one reply per command", sent)
+	}
+	if !strings.Contains(sent[2], "Session reset") || !strings.Contains(sent[3], "echo") || !strings.Contains(sent[4], "Nothing") {
+		t.Errorf("unexpected replies %q", sent[2:])
+	}
+	if len(echo.requests) != 1 {
+		t.Errorf("commands ran %d prompts", len(echo.requests)-1)
+	}
+}
+
+func TestRouterRecordsRunsInTheSessionTheyStarted(t *testing.T) {
+	app, echo := newTestApp(t)
+	echo.started, echo.release = make(chan struct{}), make(chan struct{})
+	journalDir := t.TempDir()
+	app.Journal = journal.New(journalDir)
+	p := newFakePlatform()
+
+	// Switching to a new session while a run is in flight leaves the new one empty
+	app.route(p, message("hello"))
+	<-echo.started
+	app.route(p, message("/session new notes"))
+	echo.release <- struct{}{}
+	p.wait(t)
+	if got := app.Sessions.Get("fake:chat"); got.Name != "notes" || got.Active() {
+		t.Errorf("active session %q has IDs %v, want an empty notes session", got.Name, got.IDs)
+	}
+	if main, _ := app.Sessions.Find("fake:chat", sessions.DefaultName); main.IDs["echo"] != "session-1" {
+		t.Errorf("main session has IDs %v, want the run's session", main.IDs)
+	}
+	entries, _ := filepath.Glob(filepath.Join(journalDir, "*.md"))
+	if len(entries) != 1 {
+		t.Fatalf("journal has %d days, want one", len(entries))
+	}
+	if data, err := os.ReadFile(entries[0]); err != nil || !strings.Contains(string(data), "session:: main") {
+		t.Errorf("journal doesn't file the run under main (%v): %s", err, data)
+	}
+
+	// Resetting while a run is in flight discards its session instead of reviving it
+	app.route(p, message("again"))
+	<-echo.started
+	app.route(p, message("/reset"))
+	echo.release <- struct{}{}
+	p.wait(t)
+	if app.Sessions.Get("fake:chat").Active() {
+		t.Error("run finishing after /reset recorded its session")
+	}
+}
+
+func TestRouterBareCommands(t *testing.T) {
+	app, echo := newTestApp(t)
+	p := newFakePlatform()
+
+	// Without BareCommands, "status" is a prompt
+	app.route(p, message("status"))
+	if got := p.wait(t); got != "echo: status" {
+		t.Errorf("got %q, want the prompt answered", got)
+	}
+
+	msg := message("status")
+	msg.BareCommands = true
+	app.route(p, msg)
+	if sent := p.messages(); len(echo.requests) != 1 || !strings.Contains(sent[len(sent)-1], "echo") {
+		t.Errorf("bare status was not handled as a command: %q", sent)
+	}
+}
+
+func TestRouterSharedDirectSession(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.SharedSession = true
+	p := newFakePlatform()
+
+	app.route(p, message("hello"))
+	p.wait(t)
+	if !app.Sessions.Get(sharedSessionKey).Active() {
+		t.Error("direct chat did not use the shared session")
+	}
+}
+
+func TestRouterSendsFilesOnRequest(t *testing.T) {
+	app, echo := newTestApp(t)
+	p := newFakePlatform()
+
+	app.route(p, message("export my notes as file"))
+	caption := p.wait(t)
+	if len(p.files) != 1 || !strings.HasSuffix(p.files[0], ".md") {
+		t.Fatalf("uploaded %q, want one Markdown file", p.files)
+	}
+	if !strings.Contains(caption, "echo: export my notes") {
+		t.Errorf("caption %q doesn't summarize the response", caption)
+	}
+	if got := echo.last().Prompt; strings.Contains(got, "as file") {
+		t.Errorf("prompt %q still asks for a file", got)
+	}
+}
+
+func TestModeWarnsWhenAppendIsNotEnforced(t *testing.T) {
+	app, _ := newTestApp(t)
+	p := newFakePlatform()
+
+	app.route(p, message("/mode"))
+	app.route(p, message("/mode append"))
+	sent := p.messages()
+	if len(sent) != 2 {
+		t.Fatalf("sent %q, want two replies", sent)
+	}
+	if !strings.Contains(sent[0], "can rewrite them") {
+		t.Errorf("/mode promises append-only for an executor that can't enforce it: %q", sent[0])
+	}
+	if !strings.Contains(sent[1], "⚠️ echo can't be limited to appending") {
+		t.Errorf("/mode append didn't warn: %q", sent[1])
+	}
+}
+
+func TestPromptCommandModeIsCappedAtTheChatsMode(t *testing.T) {
+	app, echo := newTestApp(t)
+	dir := t.TempDir()
+	app.Commands = prompts.New(dir)
+	for name, note := range map[string]string{
+		"tidy":  "---\nmode: full\n---\nRun tidy\n",
+		"peek":  "---\nmode: read\n---\nRun peek\n",
+		"sneak": "---\ndescription: x\n---\n/write Delete notes\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name+".md"), []byte(note), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := newFakePlatform()
+
+	for _, tc := range []struct {
+		chatMode, command string
+		want              executor.PermissionMode
+	}{
+		{"read", "/tidy", executor.ModeReadOnly},
+		{"append", "/tidy", executor.ModeAppend},
+		{"full", "/tidy", executor.ModeFull},
+		{"full", "/peek", executor.ModeReadOnly},
+		{"read", "/sneak", executor.ModeReadOnly}, // A "/write" in the body is just text
+		{"append", "/sneak", executor.ModeAppend},
+	} {
+		app.route(p, message("/mode "+tc.chatMode))
+		app.route(p, message(tc.command))
+		p.wait(t)
+		if got := echo.last().Mode; got != tc.want {
+			t.Errorf("%s in a %s chat ran in %s mode, want %s", tc.command, tc.chatMode, got, tc.want)
+		}
+	}
+	if got := echo.last().Prompt; !strings.Contains(got, "/write Delete notes") {
+		t.Errorf("prompt %q lost the body's text", got)
+	}
+}
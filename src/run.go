@@ -0,0 +1,47 @@
+// Package main provides session bookkeeping for runs shared by the messaging platforms.
+package main
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gpng/obsidian-pa/src/executor"
+	"github.com/gpng/obsidian-pa/src/sessions"
+)
+
+// resumeSessions starts a run in a conversation's active session, returning the CLI
+// sessions to resume and those to fork from (after /session fork)
+func (a *App) resumeSessions(key string) (run sessions.Run, ids, forks executor.Sessions) {
+	run, session := a.Sessions.Start(key)
+	return run, session.IDs, session.Forks
+}
+
+// recordSession stores the answering executor's session ID for the session the run
+// started in (also on failure, so the user can retry in the same session). The prompt
+// becomes the session's title if it has none.
+func (a *App) recordSession(run sessions.Run, res *executor.Result, prompt string) {
+	if res.SessionID == "" {
+		return
+	}
+	err := a.Sessions.Record(run, res.Executor, res.Model, res.SessionID, sessionTitle(prompt))
+	switch {
+	case errors.Is(err, sessions.ErrCleared):
+		log.Printf("[%s] Session %s was reset during the run, not resuming it", run.Key, run.Name)
+	case err != nil:
+		log.Printf("Failed to save session: %v", err)
+	}
+}
+
+// titleSession describes the conversation's active session if it has no title yet
+func (a *App) titleSession(key, title string) {
+	if err := a.Sessions.SetTitle(key, title); err != nil {
+		log.Printf("Failed to save session title: %v", err)
+	}
+}
+
+// resetSession forgets a conversation's session so the next message starts a new one
+func (a *App) resetSession(key string) {
+	if err := a.Sessions.Reset(key); err != nil {
+		log.Printf("Failed to save session reset: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+// Package main provides per-chat executor and model selection.
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gpng/obsidian-pa/src/executor"
+	"github.com/gpng/obsidian-pa/src/prefs"
+)
+
+// selection returns the executor name and model for a chat (empty model = executor default)
+func (a *App) selection(chatKey string) (executorName, model string) {
+	chat := a.Prefs.Get(chatKey)
+	if e, ok := a.Registry.Lookup(chat.Executor); ok {
+		return e.Name(), chat.Model
+	}
+	// No (or no longer configured) choice - use the primary with its default model
+	return a.Registry.Executors()[0].Name(), ""
+}
+
+// mode returns the permission mode for a chat
+func (a *App) mode(chatKey string) executor.PermissionMode {
+	if mode, ok := executor.ParsePermissionMode(a.Prefs.Get(chatKey).Mode); ok {
+		return mode
+	}
+	return a.Mode
+}
+
+// selectionStatus describes the chat's active executor, model and mode for /status
+func (a *App) selectionStatus(chatKey string) string {
+	name, model := a.selection(chatKey)
+	if model == "" {
+		model = a.Registry.DefaultModel(name)
+	}
+	return fmt.Sprintf("🤖 %s · %s · %s mode", name, model, a.mode(chatKey))
+}
+
+// modePrefixes are one-off permission mode prefixes. The "!" forms exist because
+// Slack swallows unknown slash commands.
+var modePrefixes = map[string]executor.PermissionMode{
+	"/write":  executor.ModeFull,
+	"!write":  executor.ModeFull,
+	"/append": executor.ModeAppend,
+	"!append": executor.ModeAppend,
+	"/read":   executor.ModeReadOnly,
+	"!read":   executor.ModeReadOnly,
+}
+
+// startPrompt returns the prompt used for the /start command
+func (a *App) startPrompt() string {
+	return a.Registry.Executors()[0].GetStartPrompt()
+}
+
+// prepareRun resolves the chain and request for a message in a chat.
+// A leading "/write", "/append" or "/read" runs that one message in that mode, and a
+// leading "@alias" (e.g. "@opus plan my week") runs it on another model or executor,
+// without changing the chat's settings. Both can be combined: "/write @opus ...".
+func (a *App) prepareRun(chatKey, text string) (*executor.Chain, executor.Request) {
+	name, model := a.selection(chatKey)
+	mode := a.mode(chatKey)
+
+	if prefix, rest, ok := strings.Cut(text, " "); ok && strings.TrimSpace(rest) != "" {
+		if prefixMode, found := modePrefixes[strings.ToLower(prefix)]; found {
+			mode, text = prefixMode, strings.TrimSpace(rest)
+		}
+	}
+
+	if alias, rest, ok := strings.Cut(text, " "); ok && strings.HasPrefix(alias, "@") && strings.TrimSpace(rest) != "" {
+		alias = strings.TrimPrefix(alias, "@")
+		if executorName, modelID, found := a.Registry.FindModel(alias); found {
+			log.Printf("One-off run on %s · %s", executorName, modelID)
+			name, model, text = executorName, modelID, strings.TrimSpace(rest)
+		} else if e, found := a.Registry.Lookup(alias); found {
+			log.Printf("One-off run on %s", e.Name())
+			name, model, text = e.Name(), "", strings.TrimSpace(rest)
+		}
+	}
+
+	return a.Registry.Chain(name), executor.Request{Prompt: text, Model: model, Mode: mode}
+}
+
+// splitRunPrefix splits the one-off "/write" and "@alias" prefixes understood by prepareRun
+// off a message, e.g. to keep them in front when messages are combined
+func (a *App) splitRunPrefix(text string) (prefix, rest string) {
+	rest = text
+	if word, after, ok := strings.Cut(rest, " "); ok && strings.TrimSpace(after) != "" {
+		if _, found := modePrefixes[strings.ToLower(word)]; found {
+			prefix, rest = word+" ", strings.TrimSpace(after)
+		}
+	}
+
+	if word, after, ok := strings.Cut(rest, " "); ok && strings.HasPrefix(word, "@") && strings.TrimSpace(after) != "" {
+		alias := strings.TrimPrefix(word, "@")
+		_, _, isModel := a.Registry.FindModel(alias)
+		_, isExecutor := a.Registry.Lookup(alias)
+		if isModel || isExecutor {
+			prefix, rest = prefix+word+" ", strings.TrimSpace(after)
+		}
+	}
+	return prefix, rest
+}
+
+// modeCommand handles /mode: shows the mode without an argument, otherwise sets the chat's mode
+func (a *App) modeCommand(chatKey, arg string) string {
+	if arg == "" {
+		appendMode := "• append - also add to notes, never rewrite them\n"
+		if !a.enforcesAppend(chatKey) {
+			appendMode = "• append - also create and edit notes, no commands; the AI is asked to only add to notes, but can rewrite them\n"
+		}
+		return fmt.Sprintf("🔐 Permission mode: %s\n\n"+
+			"• read - search and read notes only\n"+
+			appendMode+
+			"• full - edit anything, run commands\n\n"+
+			"Use /mode <name> to switch, or start a single message with /write, /append or /read (or !write, !append, !read).",
+			a.mode(chatKey))
+	}
+
+	mode, ok := executor.ParsePermissionMode(arg)
+	if !ok {
+		return fmt.Sprintf("❌ Unknown mode: %s. Use read, append or full.", arg)
+	}
+
+	if err := a.Prefs.Update(chatKey, func(chat *prefs.Chat) {
+		chat.Mode = string(mode)
+	}); err != nil {
+		log.Printf("Failed to save chat preferences: %v", err)
+		return fmt.Sprintf("❌ Failed to save mode: %v", err)
+	}
+
+	log.Printf("[%s] Permission mode set to %s", chatKey, mode)
+	if mode == executor.ModeAppend && !a.enforcesAppend(chatKey) {
+		name, _ := a.selection(chatKey)
+		return fmt.Sprintf("✅ Permission mode set to append for this chat.\n\n"+
+			"⚠️ %s can't be limited to appending: it can still edit existing notes and is only asked not to rewrite them.", a.Registry.Chain(name).Name())
+	}
+	return fmt.Sprintf("✅ Permission mode set to %s for this chat.", mode)
+}
+
+// enforcesAppend reports whether the chat's executors enforce append mode
+func (a *App) enforcesAppend(chatKey string) bool {
+	name, _ := a.selection(chatKey)
+	return a.Registry.Chain(name).EnforcesAppend()
+}
+
+// modelCommand handles /model: lists models without an argument, otherwise switches the chat's model
+// (and its executor, if the model belongs to another one)
+func (a *App) modelCommand(chatKey, arg string) string {
+	if arg == "" {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s\n\nAvailable models:", a.selectionStatus(chatKey))
+		for _, e := range a.Registry.Executors() {
+			var names []string
+			for _, m := range a.Registry.Models(e.Name()) {
+				if m.Alias == m.ID {
+					names = append(names, m.ID)
+				} else {
+					names = append(names, fmt.Sprintf("%s (%s)", m.Alias, m.ID))
+				}
+			}
+			fmt.Fprintf(&b, "\n• %s: %s", e.Name(), strings.Join(names, ", "))
+		}
+		b.WriteString("\n\nUse /model <name> to switch, or start a message with @<name> to use a model once.")
+		return b.String()
+	}
+
+	executorName, modelID, ok := a.Registry.FindModel(arg)
+	if !ok {
+		return fmt.Sprintf("❌ Unknown model: %s. Send /model to list available models.", arg)
+	}
+
+	if err := a.Prefs.Update(chatKey, func(chat *prefs.Chat) {
+		chat.Executor = executorName
+		chat.Model = modelID
+	}); err != nil {
+		log.Printf("Failed to save chat preferences: %v", err)
+		return fmt.Sprintf("❌ Failed to save model choice: %v", err)
+	}
+
+	log.Printf("[%s] Switched to %s · %s", chatKey, executorName, modelID)
+	return fmt.Sprintf("✅ Switched to %s · %s for this chat.", executorName, modelID)
+}
+
+// executorCommand handles /executor: lists executors without an argument, otherwise switches
+// the chat's executor (using that executor's default model)
+func (a *App) executorCommand(chatKey, arg string) string {
+	if arg == "" {
+		current, _ := a.selection(chatKey)
+		var b strings.Builder
+		b.WriteString("Available executors:")
+		for _, e := range a.Registry.Executors() {
+			marker := ""
+			if e.Name() == current {
+				marker = " ✅"
+			}
+			fmt.Fprintf(&b, "\n• %s%s", e.Name(), marker)
+		}
+		b.WriteString("\n\nUse /executor <name> to switch.")
+		return b.String()
+	}
+
+	e, ok := a.Registry.Lookup(arg)
+	if !ok {
+		return fmt.Sprintf("❌ Unknown or unconfigured executor: %s. Send /executor to list them.", arg)
+	}
+
+	if err := a.Prefs.Update(chatKey, func(chat *prefs.Chat) {
+		chat.Executor = e.Name()
+		chat.Model = ""
+	}); err != nil {
+		log.Printf("Failed to save chat preferences: %v", err)
+		return fmt.Sprintf("❌ Failed to save executor choice: %v", err)
+	}
+
+	log.Printf("[%s] Switched to %s", chatKey, e.Name())
+	return fmt.Sprintf("✅ Switched to %s · %s for this chat.", e.Name(), a.Registry.DefaultModel(e.Name()))
+}
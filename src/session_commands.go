@@ -0,0 +1,108 @@
+// Package main provides the /sessions and /session commands shared by the messaging platforms.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gpng/obsidian-pa/src/sessions"
+)
+
+// maxListedSessions bounds the /sessions list
+const maxListedSessions = 10
+
+// maxTitleLength bounds session titles taken from the first message (in runes)
+const maxTitleLength = 50
+
+// sessionTitle derives a session title from a prompt's first line
+func sessionTitle(prompt string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(prompt), "\n")
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = strings.TrimSpace(string(runes[:maxTitleLength])) + "…"
+	}
+	return title
+}
+
+// sessionsCommand handles /sessions: lists the conversation's recent sessions
+func (a *App) sessionsCommand(key string) string {
+	active := a.Sessions.Get(key).Name
+	list := a.Sessions.List(key)
+	if len(list) == 0 {
+		return fmt.Sprintf("ℹ️ No sessions yet. You're in \"%s\"; the next message starts it.\n\n"+
+			"Use /session new <name> to start another one.", active)
+	}
+
+	var b strings.Builder
+	b.WriteString("🗂 Sessions\n")
+	now := time.Now()
+	for i, session := range list {
+		if i == maxListedSessions {
+			fmt.Fprintf(&b, "\n… and %d older", len(list)-maxListedSessions)
+			break
+		}
+		marker := "•"
+		if session.Name == active {
+			marker = "▶️"
+		}
+		fmt.Fprintf(&b, "\n%s %s", marker, session.Name)
+		if session.Title != "" {
+			fmt.Fprintf(&b, " - %s", session.Title)
+		}
+		fmt.Fprintf(&b, " (%s ago)", formatAge(now.Sub(session.LastUsed)))
+	}
+	b.WriteString("\n\nUse /session switch <name>, /session new <name> or /session fork [name].")
+	return b.String()
+}
+
+// sessionCommand handles /session new <name>, /session switch <name> and /session fork [name]
+func (a *App) sessionCommand(key, arg string) string {
+	sub, name, _ := strings.Cut(arg, " ")
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	var reply string
+	var err error
+	switch strings.ToLower(sub) {
+	case "":
+		return "🗂 Session: " + a.Sessions.Get(key).Name + "\n\n" +
+			"Use /sessions to list them, /session new <name>, /session switch <name> or /session fork [name]."
+
+	case "new":
+		if name == "" {
+			return "❌ Usage: /session new <name>"
+		}
+		err = a.Sessions.New(key, name)
+		reply = fmt.Sprintf("🆕 Started session \"%s\". The next message begins a fresh conversation.", name)
+
+	case "switch":
+		if name == "" {
+			return "❌ Usage: /session switch <name>"
+		}
+		err = a.Sessions.Switch(key, name)
+		reply = fmt.Sprintf("🔀 Switched to session \"%s\".", name)
+
+	case "fork":
+		name, err = a.Sessions.Fork(key, name)
+		reply = fmt.Sprintf("🍴 Forked into session \"%s\". It continues from here; the original is left unchanged.", name)
+
+	default:
+		return fmt.Sprintf("❌ Unknown subcommand: %s. Use new, switch or fork.", sub)
+	}
+
+	switch {
+	case errors.Is(err, sessions.ErrExists):
+		return fmt.Sprintf("❌ A session named \"%s\" already exists. Use /session switch %s.", name, name)
+	case errors.Is(err, sessions.ErrNotFound):
+		return fmt.Sprintf("❌ No session named \"%s\". Use /sessions to list them.", name)
+	case errors.Is(err, sessions.ErrNothingToFork):
+		return "ℹ️ Nothing to fork yet - this session has no conversation."
+	case err != nil:
+		log.Printf("Failed to save sessions: %v", err)
+		return fmt.Sprintf("❌ Failed to save session: %v", err)
+	}
+
+	log.Printf("[%s] Session %s: %s", key, sub, a.Sessions.Get(key).Name)
+	return reply
+}
@@ -0,0 +1,391 @@
+// Package sessions persists resumable AI CLI sessions so conversations survive restarts.
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpng/obsidian-pa/src/executor"
+	"github.com/gpng/obsidian-pa/src/jsonfile"
+)
+
+// DefaultName is the session a conversation uses until another one is created
+const DefaultName = "main"
+
+var (
+	// ErrExists is returned when creating a session under a name already in use
+	ErrExists = errors.New("session already exists")
+	// ErrNotFound is returned when switching to a session that doesn't exist
+	ErrNotFound = errors.New("session not found")
+	// ErrNothingToFork is returned when forking a session that has no CLI session yet
+	ErrNothingToFork = errors.New("session has no conversation to fork")
+	// ErrCleared is returned when recording a run whose session was reset while it ran
+	ErrCleared = errors.New("session was reset during the run")
+)
+
+// Session is a named conversation context: its CLI sessions and when it was used
+type Session struct {
+	Name     string            `json:"-"`
+	Title    string            `json:"title,omitempty"`    // Short description, e.g. the first message
+	IDs      executor.Sessions `json:"ids"`                // CLI session ID per executor
+	Forks    executor.Sessions `json:"forks,omitempty"`    // Parent CLI sessions to fork on the next run
+	Executor string            `json:"executor,omitempty"` // Executor that answered last
+	Model    string            `json:"model,omitempty"`    // Model that answered last
+	Created  time.Time         `json:"created"`
+	LastUsed time.Time         `json:"last_used"`
+}
+
+// Active reports whether the session has a CLI session to resume or fork
+func (s Session) Active() bool {
+	for _, id := range s.IDs {
+		if id != "" {
+			return true
+		}
+	}
+	return len(s.Forks) > 0
+}
+
+// Conversation holds the sessions of one chat or thread
+type Conversation struct {
+	Active   string             `json:"active,omitempty"` // Name of the active session ("" = DefaultName)
+	Sessions map[string]Session `json:"sessions"`
+}
+
+// active returns the name of the active session
+func (c Conversation) active() string {
+	if c.Active == "" {
+		return DefaultName
+	}
+	return c.Active
+}
+
+// has reports whether a session name is in use (the default session always exists)
+func (c Conversation) has(name string) bool {
+	_, ok := c.Sessions[name]
+	return ok || name == DefaultName || name == c.active()
+}
+
+// Run is the session a run resumes, captured when it starts so its result is recorded
+// there even if the user switches or resets sessions while it runs
+type Run struct {
+	Key     string // Conversation key
+	Name    string // Session name
+	Started time.Time
+}
+
+// Store is a file-backed map of conversation keys to their sessions
+type Store struct {
+	path string
+
+	mu            sync.Mutex
+	conversations map[string]Conversation
+	cleared       map[Run]time.Time // When each session was last cleared (Started unset)
+}
+
+// Open loads the store from path, starting empty if the file doesn't exist
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, conversations: make(map[string]Conversation), cleared: make(map[Run]time.Time)}
+	if err := jsonfile.Load(path, &s.conversations); err != nil {
+		return nil, fmt.Errorf("load sessions: %w", err)
+	}
+	return s, nil
+}
+
+// Len returns the number of stored sessions
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, conv := range s.conversations {
+		n += len(conv.Sessions)
+	}
+	return n
+}
+
+// Get returns a copy of a conversation's active session (zero apart from its name if there is none)
+func (s *Store) Get(key string) Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv := s.conversations[key]
+	return clone(conv.active(), conv.Sessions[conv.active()])
+}
+
+// Start returns a copy of a conversation's active session, and the run that resumes it
+func (s *Store) Start(key string) (Run, Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv := s.conversations[key]
+	name := conv.active()
+	return Run{Key: key, Name: name, Started: time.Now()}, clone(name, conv.Sessions[name])
+}
+
+// Find returns a copy of a conversation's named session
+func (s *Store) Find(key, name string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.conversations[key].Sessions[name]
+	return clone(name, session), ok
+}
+
+// List returns a conversation's sessions, most recently used first
+func (s *Store) List(key string) []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv := s.conversations[key]
+	list := make([]Session, 0, len(conv.Sessions))
+	for name, session := range conv.Sessions {
+		list = append(list, clone(name, session))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastUsed.After(list[j].LastUsed)
+	})
+	return list
+}
+
+// Record stores the CLI session ID an executor returned for the session the run started
+// in, whichever session is active now. If that session was reset since the run started,
+// the ID belongs to the discarded conversation and ErrCleared is returned instead.
+// title describes the session if it has no title yet.
+func (s *Store) Record(run Run, executorName, model, id, title string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cleared[Run{Key: run.Key, Name: run.Name}].After(run.Started) {
+		return ErrCleared
+	}
+	return s.update(run.Key, run.Name, func(session *Session) {
+		if session.IDs == nil {
+			session.IDs = make(executor.Sessions)
+		}
+		session.IDs[executorName] = id
+		delete(session.Forks, executorName) // Forked into its own session now
+		session.Executor = executorName
+		session.Model = model
+		if session.Title == "" {
+			session.Title = title
+		}
+	})
+}
+
+// SetTitle describes the active session if it has no title yet
+func (s *Store) SetTitle(key, title string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.update(key, s.conversations[key].active(), func(session *Session) {
+		if session.Title == "" {
+			session.Title = title
+		}
+	})
+}
+
+// Reset clears the active session so the next message starts a new CLI session
+func (s *Store) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clear(key, s.conversations[key].active())
+}
+
+// Clear empties a named session of a conversation, keeping the name (e.g. on expiry)
+func (s *Store) Clear(key, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clear(key, name)
+}
+
+// ExpiredSession is a session found by Store.Expired
+type ExpiredSession struct {
+	Key string // Conversation key
+	Session
+}
+
+// Expired returns the sessions with a CLI session, across all conversations, for which expired returns true
+func (s *Store) Expired(expired func(Session) bool) []ExpiredSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []ExpiredSession
+	for key, conv := range s.conversations {
+		for name, session := range conv.Sessions {
+			session = clone(name, session)
+			if session.Active() && expired(session) {
+				list = append(list, ExpiredSession{Key: key, Session: session})
+			}
+		}
+	}
+	return list
+}
+
+// clear empties a named session. Must be called with mu held.
+func (s *Store) clear(key, name string) error {
+	s.cleared[Run{Key: key, Name: name}] = time.Now()
+	conv, ok := s.conversations[key]
+	if !ok {
+		return nil
+	}
+	if name == DefaultName {
+		delete(conv.Sessions, name)
+		return s.save(key, conv)
+	}
+	now := time.Now()
+	return s.save(key, withSession(conv, name, Session{Created: now, LastUsed: now}))
+}
+
+// New creates an empty session and makes it the conversation's active one
+func (s *Store) New(key, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv := s.conversations[key]
+	if conv.has(name) {
+		return ErrExists
+	}
+	now := time.Now()
+	conv = withSession(conv, name, Session{Created: now, LastUsed: now})
+	conv.Active = name
+	return s.save(key, conv)
+}
+
+// Switch makes an existing session the conversation's active one
+func (s *Store) Switch(key, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv := s.conversations[key]
+	if !conv.has(name) {
+		return ErrNotFound
+	}
+	conv.Active = name
+	return s.save(key, conv)
+}
+
+// Fork creates a session that branches off the active one: each executor's next run
+// forks the active session's CLI session, leaving the original untouched. An empty
+// name picks one from the active session's name. Returns the new session's name.
+func (s *Store) Fork(key, name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv := s.conversations[key]
+	parentName := conv.active()
+	parent := conv.Sessions[parentName]
+	if !parent.Active() {
+		return "", ErrNothingToFork
+	}
+
+	if name == "" {
+		name = parentName + "-fork"
+		for i := 2; conv.has(name); i++ {
+			name = fmt.Sprintf("%s-fork-%d", parentName, i)
+		}
+	}
+	if conv.has(name) {
+		return "", ErrExists
+	}
+
+	// Fork from the parent's own sessions, or the sessions it was itself forked from
+	forks := make(executor.Sessions)
+	for executorName, id := range parent.Forks {
+		forks[executorName] = id
+	}
+	for executorName, id := range parent.IDs {
+		if id != "" {
+			forks[executorName] = id
+		}
+	}
+
+	title := parent.Title
+	if title != "" && !strings.HasSuffix(title, " (fork)") {
+		title += " (fork)"
+	}
+	now := time.Now()
+	conv = withSession(conv, name, Session{
+		Title:    title,
+		Forks:    forks,
+		Executor: parent.Executor,
+		Model:    parent.Model,
+		Created:  now,
+		LastUsed: now,
+	})
+	conv.Active = name
+	return name, s.save(key, conv)
+}
+
+// Copy makes the active session of one conversation the active session of another
+// (e.g. for a handoff between platforms), replacing a session of the same name there
+func (s *Store) Copy(fromKey, toKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from := s.conversations[fromKey]
+	name := from.active()
+	session := clone(name, from.Sessions[name])
+	session.LastUsed = time.Now()
+
+	to := withSession(s.conversations[toKey], name, session)
+	to.Active = name
+	return s.save(toKey, to)
+}
+
+// update modifies a named session of a conversation, creating it if needed. Must be
+// called with mu held.
+func (s *Store) update(key, name string, fn func(*Session)) error {
+	conv := s.conversations[key]
+	session := conv.Sessions[name]
+
+	now := time.Now()
+	if session.Created.IsZero() {
+		session.Created = now
+	}
+	session.LastUsed = now
+	fn(&session)
+	return s.save(key, withSession(conv, name, session))
+}
+
+// save stores a conversation and writes the store to disk. Must be called with mu held.
+func (s *Store) save(key string, conv Conversation) error {
+	if len(conv.Sessions) == 0 && conv.active() == DefaultName {
+		delete(s.conversations, key)
+	} else {
+		s.conversations[key] = conv
+	}
+	return jsonfile.Save(s.path, s.conversations)
+}
+
+// withSession returns conv with the named session set, copying its session map
+func withSession(conv Conversation, name string, session Session) Conversation {
+	sessions := make(map[string]Session, len(conv.Sessions)+1)
+	for n, existing := range conv.Sessions {
+		sessions[n] = existing
+	}
+	sessions[name] = session
+	conv.Sessions = sessions
+	return conv
+}
+
+// clone returns a copy of a session that shares no maps with the store
+func clone(name string, session Session) Session {
+	session.Name = name
+	ids := make(executor.Sessions, len(session.IDs))
+	for executorName, id := range session.IDs {
+		ids[executorName] = id
+	}
+	session.IDs = ids
+	if session.Forks != nil {
+		forks := make(executor.Sessions, len(session.Forks))
+		for executorName, id := range session.Forks {
+			forks[executorName] = id
+		}
+		session.Forks = forks
+	}
+	return session
+}
@@ -1,12 +1,14 @@
-// Package main provides Slack Socket Mode bot functionality.
+// Package main provides the Slack Socket Mode adapter.
 package main
 
 import (
-	"fmt"
 	"log"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
-	"github.com/gpng/obsidian-pa/src/executor"
+	"github.com/gpng/obsidian-pa/src/render"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"github.com/slack-go/slack/socketmode"
@@ -19,30 +21,82 @@ type SlackConfig struct {
 	AllowedUserID string
 }
 
-// runSlackBot starts the Slack bot using Socket Mode and listens for DM messages
-func runSlackBot(slackConfig *SlackConfig, exec executor.Executor) {
+// maxSlackBlocks is the number of blocks Slack accepts in one message
+const maxSlackBlocks = 50
+
+// runSlackBot starts the Slack bot using Socket Mode and routes its DM messages
+func runSlackBot(slackConfig *SlackConfig, app *App) {
+	platform := newSlackPlatform(slackConfig)
+
+	log.Printf("[Slack] Bot is running and listening for messages (using %s)...", app.Registry.Chain("").Name())
+
+	// Start the event loop (blocking)
+	if err := app.Serve(platform); err != nil {
+		log.Fatalf("[Slack] Failed to run event loop: %v", err)
+	}
+}
+
+// slackPlatform is the Slack adapter, receiving direct messages over Socket Mode
+type slackPlatform struct {
+	api           *slack.Client
+	allowedUserID string
+	senderName    string       // The allowed user's name, for the prompt envelope
+	home          Conversation // Direct chat with the allowed user (empty = couldn't be opened)
+}
+
+// newSlackPlatform creates the Slack API client and looks up the allowed user's direct chat
+func newSlackPlatform(slackConfig *SlackConfig) *slackPlatform {
 	// Initialize Slack API client
 	api := slack.New(
 		slackConfig.BotToken,
 		slack.OptionAppLevelToken(slackConfig.AppToken),
 	)
 
+	s := &slackPlatform{
+		api:           api,
+		allowedUserID: slackConfig.AllowedUserID,
+		senderName:    slackUserName(api, slackConfig.AllowedUserID),
+	}
+
+	// The direct chat with the allowed user is where /handoff from other platforms lands
+	if dm, _, _, err := api.OpenConversation(&slack.OpenConversationParameters{Users: []string{slackConfig.AllowedUserID}}); err != nil {
+		log.Printf("[Slack] Failed to open direct chat, handoff to Slack disabled: %v", err)
+	} else {
+		s.home = Conversation{Chat: dm.ID, Direct: true}
+	}
+	return s
+}
+
+// Name identifies the platform
+func (s *slackPlatform) Name() string {
+	return "slack"
+}
+
+// AllowedUsers returns the allowed user's ID
+func (s *slackPlatform) AllowedUsers() []string {
+	return []string{s.allowedUserID}
+}
+
+// Home returns the direct chat with the allowed user, if it could be opened
+func (s *slackPlatform) Home() (Conversation, bool) {
+	return s.home, s.home.Chat != ""
+}
+
+// Receive runs the Socket Mode event loop, passing on direct messages
+func (s *slackPlatform) Receive(handle func(Message)) error {
 	// Create Socket Mode client
-	client := socketmode.New(api)
+	client := socketmode.New(s.api)
 
 	// Create Socket Mode handler
 	handler := socketmode.NewSocketmodeHandler(client)
 
-	// Session ID for conversation continuity (Slack-specific)
-	var sessionID string
-
 	// Handle connection events
 	handler.Handle(socketmode.EventTypeConnecting, func(evt *socketmode.Event, c *socketmode.Client) {
 		log.Println("[Slack] Connecting to Slack...")
 	})
 
 	handler.Handle(socketmode.EventTypeConnected, func(evt *socketmode.Event, c *socketmode.Client) {
-		log.Printf("[Slack] Connected to Slack Socket Mode (using %s)", exec.Name())
+		log.Println("[Slack] Connected to Slack Socket Mode")
 	})
 
 	handler.Handle(socketmode.EventTypeConnectionError, func(evt *socketmode.Event, c *socketmode.Client) {
@@ -85,158 +139,124 @@ func runSlackBot(slackConfig *SlackConfig, exec executor.Executor) {
 			return
 		}
 
-		// Authenticate user
-		if msgEvent.User != slackConfig.AllowedUserID {
-			log.Printf("[Slack] Unauthorized access attempt from user ID: %s", msgEvent.User)
-			return
-		}
-
-		userMsg := msgEvent.Text
-		channelID := msgEvent.Channel
-
-		if userMsg == "" {
-			return
-		}
-
-		log.Printf("[Slack] Received message from authorized user: %s", userMsg)
-
-		// Handle /reset command (also support "reset" without slash for Slack)
-		if userMsg == "/reset" || userMsg == "reset" {
-			sessionID = ""
-			log.Println("[Slack] Session reset")
-			sendSlackMessage(api, channelID, "🔄 Session reset. Starting fresh conversation.")
-			return
+		// Each thread is its own conversation; top-level messages share the channel's
+		conv := Conversation{Chat: msgEvent.Channel, Thread: msgEvent.ThreadTimeStamp, Direct: msgEvent.ThreadTimeStamp == ""}
+		msg := Message{
+			Conversation: conv,
+			UserID:       msgEvent.User,
+			Sender:       s.senderName,
+			Text:         msgEvent.Text,
+			Sent:         slackMessageTime(msgEvent.TimeStamp),
+			BareCommands: true,
 		}
-
-		// Handle /status command
-		if userMsg == "/status" || userMsg == "status" {
-			var statusMsg string
-			if sessionID != "" {
-				statusMsg = fmt.Sprintf("✅ Active session: %s", sessionID)
-			} else {
-				statusMsg = "ℹ️ No active session. Next message will start a new one."
+		if conv.Thread != "" {
+			msg.ThreadParent = func() string {
+				return s.threadParent(conv)
 			}
-			sendSlackMessage(api, channelID, statusMsg)
-			return
 		}
+		handle(msg)
+	})
 
-		// Handle /start command - Read context and start daily review
-		if userMsg == "/start" || userMsg == "start" {
-			// Reset session for a fresh start
-			sessionID = ""
-			log.Printf("[Slack] Starting new session with %s context", exec.Name())
-
-			// Send processing indicator
-			processingTs := sendSlackMessage(api, channelID, "🌅 Starting your day... Reading context and reviewing tasks...")
+	// Default handler to catch any unhandled events (for debugging)
+	handler.HandleDefault(func(evt *socketmode.Event, c *socketmode.Client) {
+		log.Printf("[Slack] Unhandled event type: %s", evt.Type)
+	})
 
-			// Execute AI CLI with the start prompt
-			response, newSessionID := exec.Execute(exec.GetStartPrompt(), sessionID)
+	return handler.RunEventLoop()
+}
 
-			// Update session ID
-			if newSessionID != "" {
-				sessionID = newSessionID
-				log.Printf("[Slack] New session started: %s", sessionID)
-			}
+// Send sends a plain text message and returns its timestamp
+func (s *slackPlatform) Send(conv Conversation, text string) (string, error) {
+	return s.post(conv, slack.MsgOptionText(text, false))
+}
 
-			// Delete processing message
-			if processingTs != "" {
-				deleteSlackMessage(api, channelID, processingTs)
-			}
+// SendMarkdown sends Markdown as Block Kit blocks, split into readable messages
+func (s *slackPlatform) SendMarkdown(conv Conversation, md string) error {
+	// Keep messages readable; long parts are split into sections by render.SlackBlocks
+	const maxLength = 3000
 
-			// Send response
-			sendSlackResponse(api, channelID, response)
-			return
+	for _, chunk := range render.Chunk(md, maxLength, utf8.RuneCountInString) {
+		// The AI writes standard Markdown; Slack gets it as Block Kit blocks with mrkdwn
+		options := []slack.MsgOption{slack.MsgOptionText(render.Plain(chunk), false)} // Fallback for notifications
+		if blocks := render.SlackBlocks(chunk); len(blocks) <= maxSlackBlocks {
+			options = append(options, slack.MsgOptionBlocks(blocks...))
+		} else {
+			options[0] = slack.MsgOptionText(render.SlackMrkdwn(chunk), false)
 		}
 
-		// Send processing indicator
-		processingTs := sendSlackMessage(api, channelID, "🧠 Processing...")
-
-		// Execute AI CLI
-		response, newSessionID := exec.Execute(userMsg, sessionID)
-
-		// Update session ID if we got a new one
-		if newSessionID != "" {
-			sessionID = newSessionID
-			log.Printf("[Slack] Session ID: %s", sessionID)
+		if _, err := s.post(conv, options...); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		// Delete processing message
-		if processingTs != "" {
-			deleteSlackMessage(api, channelID, processingTs)
-		}
+// Edit replaces the text of a message by its timestamp
+func (s *slackPlatform) Edit(conv Conversation, id, text string) error {
+	_, _, _, err := s.api.UpdateMessage(conv.Chat, id, slack.MsgOptionText(text, false))
+	return err
+}
 
-		// Send response
-		sendSlackResponse(api, channelID, response)
-	})
+// Delete deletes a message by its timestamp
+func (s *slackPlatform) Delete(conv Conversation, id string) error {
+	_, _, err := s.api.DeleteMessage(conv.Chat, id)
+	return err
+}
 
-	// Default handler to catch any unhandled events (for debugging)
-	handler.HandleDefault(func(evt *socketmode.Event, c *socketmode.Client) {
-		log.Printf("[Slack] Unhandled event type: %s", evt.Type)
+// Upload uploads a file with the caption as its comment (needs files:write)
+func (s *slackPlatform) Upload(conv Conversation, name string, content []byte, caption string) error {
+	_, err := s.api.UploadFileV2(slack.UploadFileV2Parameters{
+		Content:         string(content),
+		FileSize:        len(content),
+		Filename:        name,
+		Title:           strings.TrimSuffix(name, ".md"),
+		InitialComment:  render.SlackMrkdwn(caption),
+		Channel:         conv.Chat,
+		ThreadTimestamp: conv.Thread,
+		SnippetType:     "markdown",
 	})
+	return err
+}
 
-	log.Println("[Slack] Bot is running and listening for messages...")
-
-	// Start the event loop (blocking)
-	if err := handler.RunEventLoop(); err != nil {
-		log.Fatalf("[Slack] Failed to run event loop: %v", err)
+// post sends a message to the conversation, replying in its thread if it has one
+func (s *slackPlatform) post(conv Conversation, options ...slack.MsgOption) (string, error) {
+	if conv.Thread != "" {
+		options = append(options, slack.MsgOptionTS(conv.Thread))
 	}
+	_, ts, err := s.api.PostMessage(conv.Chat, options...)
+	return ts, err
 }
 
-// sendSlackMessage sends a single message to Slack and returns the timestamp (for deletion)
-func sendSlackMessage(api *slack.Client, channelID, text string) string {
-	_, ts, err := api.PostMessage(
-		channelID,
-		slack.MsgOptionText(text, false),
-	)
-	if err != nil {
-		log.Printf("[Slack] Failed to send message: %v", err)
+// threadParent returns the text of the message a thread replies to
+func (s *slackPlatform) threadParent(conv Conversation) string {
+	msgs, _, _, err := s.api.GetConversationReplies(&slack.GetConversationRepliesParameters{
+		ChannelID: conv.Chat,
+		Timestamp: conv.Thread,
+		Limit:     1,
+	})
+	if err != nil || len(msgs) == 0 {
+		log.Printf("[Slack] Failed to fetch thread parent: %v", err)
 		return ""
 	}
-	return ts
+	return msgs[0].Text
 }
 
-// deleteSlackMessage deletes a message by its timestamp
-func deleteSlackMessage(api *slack.Client, channelID, timestamp string) {
-	_, _, err := api.DeleteMessage(channelID, timestamp)
+// slackUserName returns a user's display name, or "" if it can't be looked up (needs users:read)
+func slackUserName(api *slack.Client, userID string) string {
+	user, err := api.GetUserInfo(userID)
 	if err != nil {
-		log.Printf("[Slack] Failed to delete message: %v", err)
+		log.Printf("[Slack] Failed to look up user name: %v", err)
+		return ""
 	}
+	return orDefault(user.Profile.DisplayName, user.RealName)
 }
 
-// sendSlackResponse sends a response to Slack, splitting it if necessary for readability
-func sendSlackResponse(api *slack.Client, channelID, response string) {
-	// Slack section blocks have a 3000 char limit for text
-	const maxLength = 3000
-
-	// Split response if too long
-	for len(response) > 0 {
-		chunk := response
-		if len(chunk) > maxLength {
-			// Try to split at a newline
-			splitIdx := strings.LastIndex(response[:maxLength], "\n")
-			if splitIdx == -1 || splitIdx < maxLength/2 {
-				splitIdx = maxLength
-			}
-			chunk = response[:splitIdx]
-			response = response[splitIdx:]
-		} else {
-			response = ""
-		}
-
-		// Use blocks with mrkdwn for proper formatting
-		textBlock := slack.NewTextBlockObject("mrkdwn", chunk, false, false)
-		section := slack.NewSectionBlock(textBlock, nil, nil)
-
-		_, _, err := api.PostMessage(
-			channelID,
-			slack.MsgOptionBlocks(section),
-			slack.MsgOptionText(chunk, false), // Fallback for notifications
-		)
-		if err != nil {
-			log.Printf("[Slack] Failed to send response: %v", err)
-			// Send error message
-			api.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("❌ Failed to send response: %v", err), false))
-			return
-		}
+// slackMessageTime parses a Slack message timestamp such as "1712345678.123456"
+func slackMessageTime(ts string) time.Time {
+	seconds, _, _ := strings.Cut(ts, ".")
+	unix, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}
 	}
+	return time.Unix(unix, 0)
 }
@@ -1,13 +1,13 @@
-// Package main provides Telegram bot functionality.
+// Package main provides the Telegram adapter.
 package main
 
 import (
-	"fmt"
 	"log"
+	"strconv"
 	"strings"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"github.com/gpng/obsidian-pa/src/executor"
+	"github.com/gpng/obsidian-pa/src/render"
 )
 
 // TelegramConfig holds Telegram-specific configuration
@@ -16,161 +16,147 @@ type TelegramConfig struct {
 	AllowedUserID int64
 }
 
-// runTelegramBot starts the Telegram bot and listens for messages
-func runTelegramBot(tgConfig *TelegramConfig, exec executor.Executor) {
+// runTelegramBot starts the Telegram bot and routes its messages
+func runTelegramBot(tgConfig *TelegramConfig, app *App) {
 	// Initialize Telegram bot
-	bot, err := tgbotapi.NewBotAPI(tgConfig.Token)
+	platform, err := newTelegramPlatform(tgConfig)
 	if err != nil {
 		log.Fatalf("Failed to create Telegram bot: %v", err)
 	}
 
-	log.Printf("[Telegram] Authorized on account %s (using %s)", bot.Self.UserName, exec.Name())
-
-	// Set up updates channel
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-	updates := bot.GetUpdatesChan(u)
-
+	log.Printf("[Telegram] Authorized on account %s (using %s)", platform.bot.Self.UserName, app.Registry.Chain("").Name())
 	log.Println("[Telegram] Bot is running and listening for messages...")
 
-	// Session ID for conversation continuity (Telegram-specific)
-	var sessionID string
-
-	for update := range updates {
-		if update.Message == nil {
-			continue
-		}
-
-		// Authenticate user
-		if update.Message.From.ID != tgConfig.AllowedUserID {
-			log.Printf("[Telegram] Unauthorized access attempt from user ID: %d", update.Message.From.ID)
-			continue
-		}
-
-		userMsg := update.Message.Text
-		chatID := update.Message.Chat.ID
-
-		if userMsg == "" {
-			continue
-		}
-
-		log.Printf("[Telegram] Received message from authorized user: %s", userMsg)
+	if err := app.Serve(platform); err != nil {
+		log.Fatalf("[Telegram] Stopped receiving messages: %v", err)
+	}
+}
 
-		// Handle /reset command
-		if userMsg == "/reset" {
-			sessionID = ""
-			log.Println("[Telegram] Session reset")
-			msg := tgbotapi.NewMessage(chatID, "🔄 Session reset. Starting fresh conversation.")
-			bot.Send(msg)
-			continue
-		}
+// telegramPlatform is the Telegram adapter, receiving messages by long polling
+type telegramPlatform struct {
+	bot           *tgbotapi.BotAPI
+	allowedUserID int64
+}
 
-		// Handle /status command
-		if userMsg == "/status" {
-			var statusMsg string
-			if sessionID != "" {
-				statusMsg = fmt.Sprintf("✅ Active session: %s", sessionID)
-			} else {
-				statusMsg = "ℹ️ No active session. Next message will start a new one."
-			}
-			msg := tgbotapi.NewMessage(chatID, statusMsg)
-			bot.Send(msg)
-			continue
-		}
+// newTelegramPlatform connects to the Telegram Bot API
+func newTelegramPlatform(tgConfig *TelegramConfig) (*telegramPlatform, error) {
+	bot, err := tgbotapi.NewBotAPI(tgConfig.Token)
+	if err != nil {
+		return nil, err
+	}
+	return &telegramPlatform{bot: bot, allowedUserID: tgConfig.AllowedUserID}, nil
+}
 
-		// Handle /start command - Read context and start daily review
-		if userMsg == "/start" {
-			// Reset session for a fresh start
-			sessionID = ""
-			log.Printf("[Telegram] Starting new session with %s context", exec.Name())
-
-			// Send processing indicator
-			processingMsg := tgbotapi.NewMessage(chatID, "🌅 Starting your day... Reading context and reviewing tasks...")
-			sentMsg, err := bot.Send(processingMsg)
-			if err != nil {
-				log.Printf("[Telegram] Failed to send processing message: %v", err)
-			}
+// Name identifies the platform
+func (t *telegramPlatform) Name() string {
+	return "telegram"
+}
 
-			// Execute AI CLI with the start prompt
-			response, newSessionID := exec.Execute(exec.GetStartPrompt(), sessionID)
+// AllowedUsers returns the allowed user's ID
+func (t *telegramPlatform) AllowedUsers() []string {
+	return []string{strconv.FormatInt(t.allowedUserID, 10)}
+}
 
-			// Update session ID
-			if newSessionID != "" {
-				sessionID = newSessionID
-				log.Printf("[Telegram] New session started: %s", sessionID)
-			}
+// Home returns the private chat with the allowed user, whose chat ID is their user ID
+func (t *telegramPlatform) Home() (Conversation, bool) {
+	return Conversation{Chat: strconv.FormatInt(t.allowedUserID, 10), Direct: true}, true
+}
 
-			// Delete processing message
-			if err == nil {
-				deleteMsg := tgbotapi.NewDeleteMessage(chatID, sentMsg.MessageID)
-				bot.Request(deleteMsg)
-			}
+// Receive long-polls for messages
+func (t *telegramPlatform) Receive(handle func(Message)) error {
+	// Set up updates channel
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
 
-			// Send response
-			sendTelegramResponse(bot, chatID, response)
+	for update := range getTelegramUpdates(t.bot, u) {
+		m := update.Message
+		if m == nil || m.From == nil {
 			continue
 		}
 
-		// Send processing indicator
-		processingMsg := tgbotapi.NewMessage(chatID, "🧠 Processing...")
-		sentMsg, err := bot.Send(processingMsg)
-		if err != nil {
-			log.Printf("[Telegram] Failed to send processing message: %v", err)
+		// Each chat, and each forum topic within a group, is its own conversation
+		conv := Conversation{Chat: strconv.FormatInt(m.Chat.ID, 10), Direct: m.Chat.IsPrivate()}
+		if update.ThreadID != 0 {
+			conv.Thread = strconv.Itoa(update.ThreadID)
 		}
 
-		// Execute AI CLI
-		response, newSessionID := exec.Execute(userMsg, sessionID)
-
-		// Update session ID if we got a new one
-		if newSessionID != "" {
-			sessionID = newSessionID
-			log.Printf("[Telegram] Session ID: %s", sessionID)
+		msg := Message{
+			Conversation: conv,
+			UserID:       strconv.FormatInt(m.From.ID, 10),
+			Sender:       strings.TrimSpace(m.From.FirstName + " " + m.From.LastName),
+			Text:         m.Text,
+			Sent:         m.Time(),
 		}
-
-		// Delete processing message
-		if err == nil {
-			deleteMsg := tgbotapi.NewDeleteMessage(chatID, sentMsg.MessageID)
-			bot.Request(deleteMsg)
+		if reply := m.ReplyToMessage; reply != nil {
+			msg.ReplyTo = orDefault(reply.Text, reply.Caption)
 		}
-
-		// Send response (split if too long for Telegram's 4096 char limit)
-		sendTelegramResponse(bot, chatID, response)
+		handle(msg)
 	}
+	return nil
 }
 
-// sendTelegramResponse sends a message to Telegram, splitting it if necessary
-func sendTelegramResponse(bot *tgbotapi.BotAPI, chatID int64, response string) {
-	const maxLength = 4096
-
-	// Split response if too long
-	for len(response) > 0 {
-		chunk := response
-		if len(chunk) > maxLength {
-			// Try to split at a newline
-			splitIdx := strings.LastIndex(response[:maxLength], "\n")
-			if splitIdx == -1 || splitIdx < maxLength/2 {
-				splitIdx = maxLength
-			}
-			chunk = response[:splitIdx]
-			response = response[splitIdx:]
-		} else {
-			response = ""
-		}
+// Send sends a plain text message
+func (t *telegramPlatform) Send(conv Conversation, text string) (string, error) {
+	msg, err := sendTelegramMessage(t.bot, telegramConv(conv), text, "")
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(msg.MessageID), nil
+}
 
-		msg := tgbotapi.NewMessage(chatID, chunk)
-		msg.ParseMode = "Markdown" // Enable Markdown rendering
+// SendMarkdown sends Markdown as HTML, split to fit Telegram's message limit
+func (t *telegramPlatform) SendMarkdown(conv Conversation, md string) error {
+	// Telegram allows 4096 UTF-16 units of text after formatting is applied. The
+	// Markdown is measured before rendering, which leaves room for table padding.
+	const maxLength = 4000
 
-		_, err := bot.Send(msg)
+	for _, chunk := range render.Chunk(md, maxLength, render.UTF16Length) {
+		_, err := sendTelegramMessage(t.bot, telegramConv(conv), render.TelegramHTML(chunk), "HTML")
 		if err != nil {
-			// If Markdown parsing fails, try again without it
-			log.Printf("[Telegram] Failed to send with Markdown, retrying as plain text: %v", err)
-			msg.ParseMode = ""
-			if _, err := bot.Send(msg); err != nil {
-				log.Printf("[Telegram] Failed to send response: %v", err)
-				errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Failed to send response: %v", err))
-				bot.Send(errMsg)
-				return
+			// If the formatted message is rejected, try again without formatting
+			log.Printf("[Telegram] Failed to send with HTML, retrying as plain text: %v", err)
+			if _, err := sendTelegramMessage(t.bot, telegramConv(conv), render.Plain(chunk), ""); err != nil {
+				return err
 			}
 		}
 	}
+	return nil
+}
+
+// Edit replaces the text of a message
+func (t *telegramPlatform) Edit(conv Conversation, id, text string) error {
+	messageID, err := strconv.Atoi(id)
+	if err != nil {
+		return err
+	}
+	_, err = t.bot.Request(tgbotapi.NewEditMessageText(telegramConv(conv).ChatID, messageID, text))
+	return err
+}
+
+// Delete deletes a message
+func (t *telegramPlatform) Delete(conv Conversation, id string) error {
+	messageID, err := strconv.Atoi(id)
+	if err != nil {
+		return err
+	}
+	_, err = t.bot.Request(tgbotapi.NewDeleteMessage(telegramConv(conv).ChatID, messageID))
+	return err
+}
+
+// Upload sends a document with the caption as HTML
+func (t *telegramPlatform) Upload(conv Conversation, name string, content []byte, caption string) error {
+	file := tgbotapi.FileBytes{Name: name, Bytes: content}
+	err := sendTelegramDocument(t.bot, telegramConv(conv), file, render.TelegramHTML(caption), "HTML")
+	if err != nil {
+		log.Printf("[Telegram] Failed to send file with HTML caption, retrying as plain text: %v", err)
+		err = sendTelegramDocument(t.bot, telegramConv(conv), file, render.Plain(caption), "")
+	}
+	return err
+}
+
+// telegramConv converts a conversation to Telegram's chat and forum topic IDs
+func telegramConv(conv Conversation) telegramConversation {
+	chatID, _ := strconv.ParseInt(conv.Chat, 10, 64)
+	threadID, _ := strconv.Atoi(conv.Thread)
+	return telegramConversation{ChatID: chatID, ThreadID: threadID}
 }
@@ -0,0 +1,101 @@
+// Package main provides Telegram Bot API calls for forum topics, which the
+// vendored client library predates.
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// telegramConversation identifies a Telegram chat, or a forum topic within it
+type telegramConversation struct {
+	ChatID   int64
+	ThreadID int // Forum topic (0 = the chat itself)
+}
+
+// telegramUpdate is an update with the forum topic of its message
+type telegramUpdate struct {
+	tgbotapi.Update
+	ThreadID int
+}
+
+// telegramTopic holds the forum fields of a message missing from tgbotapi.Message
+type telegramTopic struct {
+	Message *struct {
+		MessageThreadID int  `json:"message_thread_id"`
+		IsTopicMessage  bool `json:"is_topic_message"`
+	} `json:"message"`
+}
+
+// getTelegramUpdates long-polls for updates like bot.GetUpdatesChan, additionally
+// decoding the forum topic each message was sent in
+func getTelegramUpdates(bot *tgbotapi.BotAPI, config tgbotapi.UpdateConfig) <-chan telegramUpdate {
+	ch := make(chan telegramUpdate, bot.Buffer)
+
+	go func() {
+		for {
+			resp, err := bot.Request(config)
+			var updates []tgbotapi.Update
+			var topics []telegramTopic
+			if err == nil {
+				err = json.Unmarshal(resp.Result, &updates)
+			}
+			if err == nil {
+				err = json.Unmarshal(resp.Result, &topics)
+			}
+			if err != nil {
+				log.Printf("[Telegram] Failed to get updates, retrying in 3 seconds: %v", err)
+				time.Sleep(3 * time.Second)
+				continue
+			}
+
+			for i, update := range updates {
+				if update.UpdateID < config.Offset {
+					continue
+				}
+				config.Offset = update.UpdateID + 1
+
+				// Only topic messages belong to a thread; in other chats it marks a reply chain
+				var threadID int
+				if topic := topics[i].Message; topic != nil && topic.IsTopicMessage {
+					threadID = topic.MessageThreadID
+				}
+				ch <- telegramUpdate{Update: update, ThreadID: threadID}
+			}
+		}
+	}()
+
+	return ch
+}
+
+// sendTelegramMessage sends a text message to a conversation (parseMode may be empty)
+func sendTelegramMessage(bot *tgbotapi.BotAPI, conv telegramConversation, text, parseMode string) (tgbotapi.Message, error) {
+	params := tgbotapi.Params{}
+	params.AddNonZero64("chat_id", conv.ChatID)
+	params.AddNonZero("message_thread_id", conv.ThreadID)
+	params.AddNonEmpty("text", text)
+	params.AddNonEmpty("parse_mode", parseMode)
+
+	var msg tgbotapi.Message
+	resp, err := bot.MakeRequest("sendMessage", params)
+	if err != nil {
+		return msg, err
+	}
+	err = json.Unmarshal(resp.Result, &msg)
+	return msg, err
+}
+
+// sendTelegramDocument uploads a file to a conversation with a caption (parseMode may be empty)
+func sendTelegramDocument(bot *tgbotapi.BotAPI, conv telegramConversation, file tgbotapi.FileBytes, caption, parseMode string) error {
+	params := tgbotapi.Params{}
+	params.AddNonZero64("chat_id", conv.ChatID)
+	params.AddNonZero("message_thread_id", conv.ThreadID)
+	params.AddNonEmpty("caption", caption)
+	params.AddNonEmpty("parse_mode", parseMode)
+
+	_, err := bot.UploadFiles("sendDocument", params, []tgbotapi.RequestFile{{Name: "document", Data: file}})
+	return err
+}
@@ -0,0 +1,230 @@
+// Package usage records the token usage and cost of AI runs in a persistent per-day ledger.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// dayFormat names ledger files, one per local day
+const dayFormat = "2006-01-02"
+
+// Record is a single run in the ledger
+type Record struct {
+	Time             time.Time `json:"time"`
+	Platform         string    `json:"platform"`
+	Executor         string    `json:"executor"`
+	Model            string    `json:"model"`
+	InputTokens      int       `json:"input_tokens"`
+	OutputTokens     int       `json:"output_tokens"`
+	CacheReadTokens  int       `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int       `json:"cache_write_tokens,omitempty"`
+	CostUSD          float64   `json:"cost_usd"`
+	DurationMS       int64     `json:"duration_ms"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// Total aggregates records for one executor and model
+type Total struct {
+	Executor     string
+	Model        string
+	Runs         int
+	InputTokens  int
+	OutputTokens int
+	CostUSD      float64
+}
+
+// Budget holds optional spending caps in USD (0 = no cap)
+type Budget struct {
+	DailyUSD   float64
+	MonthlyUSD float64
+}
+
+// BudgetError reports a budget cap that has been reached
+type BudgetError struct {
+	Period string // "daily" or "monthly"
+	Spent  float64
+	Cap    float64
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%s budget of $%.2f reached ($%.2f spent)", e.Period, e.Cap, e.Spent)
+}
+
+// Ledger is an append-only usage log stored as one JSON-lines file per day
+type Ledger struct {
+	dir    string
+	budget Budget
+
+	mu sync.Mutex
+}
+
+// NewLedger creates a ledger in dir, creating the directory if needed
+func NewLedger(dir string, budget Budget) (*Ledger, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create usage directory: %w", err)
+	}
+	return &Ledger{dir: dir, budget: budget}, nil
+}
+
+// Budget returns the configured spending caps
+func (l *Ledger) Budget() Budget {
+	return l.budget
+}
+
+// Add appends a record to the file for its day
+func (l *Ledger) Add(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path(record.Time), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Totals aggregates records from the days in [from, to], grouped by executor and model
+func (l *Ledger) Totals(from, to time.Time) ([]Total, error) {
+	records, err := l.records(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*Total)
+	for _, r := range records {
+		key := r.Executor + "\x00" + r.Model
+		t, ok := byKey[key]
+		if !ok {
+			t = &Total{Executor: r.Executor, Model: r.Model}
+			byKey[key] = t
+		}
+		t.Runs++
+		t.InputTokens += r.InputTokens + r.CacheReadTokens + r.CacheWriteTokens
+		t.OutputTokens += r.OutputTokens
+		t.CostUSD += r.CostUSD
+	}
+
+	totals := make([]Total, 0, len(byKey))
+	for _, t := range byKey {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Executor != totals[j].Executor {
+			return totals[i].Executor < totals[j].Executor
+		}
+		return totals[i].Model < totals[j].Model
+	})
+	return totals, nil
+}
+
+// CheckBudget returns a *BudgetError if a daily or monthly cap has been reached at now
+func (l *Ledger) CheckBudget(now time.Time) error {
+	if l.budget.DailyUSD > 0 {
+		spent, err := l.spent(StartOfDay(now), now)
+		if err != nil {
+			return err
+		}
+		if spent >= l.budget.DailyUSD {
+			return &BudgetError{Period: "daily", Spent: spent, Cap: l.budget.DailyUSD}
+		}
+	}
+
+	if l.budget.MonthlyUSD > 0 {
+		spent, err := l.spent(StartOfMonth(now), now)
+		if err != nil {
+			return err
+		}
+		if spent >= l.budget.MonthlyUSD {
+			return &BudgetError{Period: "monthly", Spent: spent, Cap: l.budget.MonthlyUSD}
+		}
+	}
+
+	return nil
+}
+
+// spent returns the total cost of the days in [from, to]
+func (l *Ledger) spent(from, to time.Time) (float64, error) {
+	totals, err := l.Totals(from, to)
+	if err != nil {
+		return 0, err
+	}
+	var sum float64
+	for _, t := range totals {
+		sum += t.CostUSD
+	}
+	return sum, nil
+}
+
+// records reads every record from the day files covering [from, to]
+func (l *Ledger) records(from, to time.Time) ([]Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var records []Record
+	for day := StartOfDay(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		f, err := os.Open(l.path(day))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var r Record
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				// Skip a torn line from a crash mid-write rather than failing the report
+				log.Printf("[Usage] Skipping invalid ledger line in %s: %v", f.Name(), err)
+				continue
+			}
+			records = append(records, r)
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// path returns the ledger file for the local day of t
+func (l *Ledger) path(t time.Time) string {
+	return filepath.Join(l.dir, t.Local().Format(dayFormat)+".jsonl")
+}
+
+// StartOfDay returns midnight (local time) of the day containing t
+func StartOfDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// StartOfWeek returns midnight (local time) of the Monday of the week containing t
+func StartOfWeek(t time.Time) time.Time {
+	day := StartOfDay(t)
+	offset := (int(day.Weekday()) + 6) % 7 // Days since Monday
+	return day.AddDate(0, 0, -offset)
+}
+
+// StartOfMonth returns midnight (local time) of the first day of the month containing t
+func StartOfMonth(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+}